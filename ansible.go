@@ -0,0 +1,361 @@
+package nodeset
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ansibleLetters are the letters of Ansible alphabetic ranges, in the order they are
+// ranged over.
+const ansibleLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// FromAnsible converts an Ansible host pattern like 'web[01:50:2].example.com' into a
+// node set pattern, 'web[01-50/2].example.com'. Numeric ranges become bracket ranges,
+// keeping their step and zero padding, while alphabetic ranges like 'db-[a:c]' are
+// expanded into a pattern per letter, 'db-a,db-b,db-c'.
+func FromAnsible(host string) (string, error) {
+	patterns := []string{""}
+	for pos := 0; pos < len(host); {
+		if host[pos] != '[' {
+			end := strings.IndexByte(host[pos:], '[')
+			if end < 0 {
+				end = len(host) - pos
+			}
+			for i := range patterns {
+				patterns[i] += host[pos : pos+end]
+			}
+			pos += end
+			continue
+		}
+		end := strings.IndexByte(host[pos:], ']')
+		if end < 0 {
+			return "", parseError(ErrUnbalancedBracket, host, pos, host[pos:], "contains a left bracket without a right bracket")
+		}
+		values, err := ansibleRange(host[pos+1 : pos+end])
+		if err != nil {
+			return "", relocate(err, host, pos+1)
+		}
+		var next []string
+		for _, p := range patterns {
+			for _, v := range values {
+				next = append(next, p+v)
+			}
+		}
+		patterns = next
+		pos += end + 1
+	}
+	return strings.Join(patterns, ","), nil
+}
+
+// ansibleRange converts the contents of an Ansible range bracket, like '01:50:2' or
+// 'a:f', into a single node set bracket, or the letters of an alphabetic range.
+func ansibleRange(r string) ([]string, error) {
+	parts := strings.Split(r, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, parseError(ErrBadValue, r, 0, r, "range [%s], expected [start:end] or [start:end:step]", r)
+	}
+	step := uint64(1)
+	if len(parts) == 3 {
+		var err error
+		step, err = strconv.ParseUint(parts[2], 10, 64)
+		if err != nil || step == 0 {
+			offset := len(parts[0]) + len(parts[1]) + 2
+			return nil, parseError(ErrBadStep, r, offset, parts[2], "range [%s], contains a step that is not a positive integer", r)
+		}
+	}
+
+	if len(parts[0]) == 1 && len(parts[1]) == 1 && strings.Contains(ansibleLetters, parts[0]) && strings.Contains(ansibleLetters, parts[1]) {
+		start, end := strings.Index(ansibleLetters, parts[0]), strings.Index(ansibleLetters, parts[1])
+		if start > end {
+			return nil, parseError(ErrReversedRange, r, 0, r, "range [%s], starts with a letter after the end letter", r)
+		}
+		var letters []string
+		for i := start; i <= end; i += int(step) {
+			letters = append(letters, ansibleLetters[i:i+1])
+		}
+		return letters, nil
+	}
+
+	if len(parts[0]) > 1 && parts[0][0] == '0' && len(parts[0]) != len(parts[1]) {
+		return nil, parseError(ErrBadPadding, r, 0, r, "range [%s], zero padded start and end must have the same length", r)
+	}
+	element := parts[0] + "-" + parts[1]
+	if step != 1 {
+		element += "/" + parts[2]
+	}
+	if _, err := parseElement(element); err != nil {
+		return nil, err
+	}
+	return []string{"[" + element + "]"}, nil
+}
+
+// ToAnsible returns the nodes of ns as Ansible host patterns, like 'web[01:50].example.com'.
+// Ansible ranges hold a single range per bracket, so a folded pattern with several ranges
+// in a bracket results in a host pattern per combination of its ranges. Arithmetic
+// progressions are written as a stride, like 'node[1:9:2]' for node[1,3,5,7,9].
+func ToAnsible(ns *NodeSet) []string {
+	type shapedBox struct {
+		pattern string
+		shape   shape
+		box     box
+	}
+	var boxes []shapedBox
+	for _, group := range ns.groupMap() {
+		for _, b := range group.boxes {
+			boxes = append(boxes, shapedBox{pattern: group.shape.format(b, 0), shape: group.shape, box: b})
+		}
+	}
+	slices.SortFunc(boxes, func(x, y shapedBox) int {
+		return -strings.Compare(x.pattern, y.pattern)
+	})
+
+	var hosts []string
+	for _, sb := range boxes {
+		hosts = append(hosts, sb.shape.ansibleHosts(sb.box)...)
+	}
+	return hosts
+}
+
+// ansibleHosts returns an Ansible host pattern per combination of the intervals of b,
+// runs of single values with the same distance between them written as a stride like
+// [1:9:2]. Values Ansible ranges can't be written with, like hexadecimal ones, are listed.
+func (s shape) ansibleHosts(b box) []string {
+	hosts := []string{s.literals[0]}
+	for i, values := range b {
+		var next []string
+		for _, host := range hosts {
+			for _, iv := range ansibleIntervals(values) {
+				f := s.formats[i]
+				lo, hi := f.format(iv.lo), f.format(iv.hi)
+				switch {
+				case iv.lo == iv.hi:
+					next = append(next, host+lo+s.literals[i+1])
+				case f.notation.hex() || f.notation != Decimal && len(hi) > 1:
+					// Ansible ranges are either numeric or of single letters.
+					for v := range iv.values() {
+						next = append(next, host+f.format(v)+s.literals[i+1])
+					}
+				case iv.step != 0:
+					next = append(next, fmt.Sprintf("%s[%s:%s:%d]%s", host, lo, hi, iv.step, s.literals[i+1]))
+				default:
+					next = append(next, host+"["+lo+":"+hi+"]"+s.literals[i+1])
+				}
+			}
+		}
+		hosts = next
+	}
+	return hosts
+}
+
+// ansibleIntervals returns the intervals of r, replacing runs of at least three single
+// values forming an arithmetic progression by a stepped interval.
+func ansibleIntervals(r rangeSet) []interval {
+	var intervals []interval
+	for i := 0; i < len(r); i++ {
+		if n := r.progression(i); n >= 3 {
+			intervals = append(intervals, newInterval(r[i].lo, r[i+n-1].lo, r[i+1].lo-r[i].lo))
+			i += n - 1
+			continue
+		}
+		intervals = append(intervals, r[i])
+	}
+	return intervals
+}
+
+// ansibleInventory collects the hosts and child groups of each group of an inventory.
+type ansibleInventory struct {
+	hosts     map[string][]string // Node set patterns of the hosts of each group.
+	children  map[string][]string // Child group names of each group.
+	ungrouped []string            // Node set patterns of hosts listed outside of a group.
+}
+
+// LoadAnsibleInventory reads an Ansible inventory file in the INI format, or in the YAML
+// format for files ending with .yml or .yaml, and returns a pattern per group of the
+// inventory, that can be used as a source of a MapResolver. The pattern of a group
+// holds its hosts converted by FromAnsible, and references to its child groups. The
+// all group holds every host of the inventory, and the ungrouped group holds the hosts
+// that aren't part of any other group.
+func LoadAnsibleInventory(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	inv := &ansibleInventory{hosts: make(map[string][]string), children: make(map[string][]string)}
+	switch filepath.Ext(path) {
+	case ".yml", ".yaml":
+		err = inv.loadYAML(data)
+	default:
+		err = inv.loadINI(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("inventory %s, %w", path, err)
+	}
+	return inv.groups()
+}
+
+// loadINI adds the groups of an INI inventory to inv.
+func (inv *ansibleInventory) loadINI(data []byte) error {
+	group, kind := "", ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' || text[0] == ';' {
+			continue
+		}
+		if text[0] == '[' && text[len(text)-1] == ']' {
+			group, kind, _ = strings.Cut(text[1:len(text)-1], ":")
+			if kind == "" || kind == "children" {
+				inv.addGroup(group)
+			}
+			continue
+		}
+		entry := strings.Fields(text)[0]
+		var err error
+		switch kind {
+		case "":
+			err = inv.addHost(group, entry)
+		case "children":
+			inv.children[group] = append(inv.children[group], entry)
+		case "vars":
+		default:
+			err = fmt.Errorf("section [%s:%s], unknown section type", group, kind)
+		}
+		if err != nil {
+			return fmt.Errorf("line %d, %w", line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// loadYAML adds the groups of a YAML inventory to inv.
+func (inv *ansibleInventory) loadYAML(data []byte) error {
+	var doc map[string]*ansibleYAMLGroup
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	for name, group := range doc {
+		if err := inv.addYAMLGroup(name, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ansibleYAMLGroup is a group of a YAML inventory.
+type ansibleYAMLGroup struct {
+	Hosts    map[string]any               `yaml:"hosts"`
+	Children map[string]*ansibleYAMLGroup `yaml:"children"`
+}
+
+// addYAMLGroup adds a group of a YAML inventory and its children to inv.
+func (inv *ansibleInventory) addYAMLGroup(name string, group *ansibleYAMLGroup) error {
+	inv.addGroup(name)
+	if group == nil {
+		return nil
+	}
+	for _, host := range slices.Sorted(maps.Keys(group.Hosts)) {
+		if err := inv.addHost(name, host); err != nil {
+			return fmt.Errorf("group %s, %w", name, err)
+		}
+	}
+	for child, g := range group.Children {
+		inv.children[name] = append(inv.children[name], child)
+		if err := inv.addYAMLGroup(child, g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addGroup adds group to inv, so groups without hosts are kept.
+func (inv *ansibleInventory) addGroup(group string) {
+	if _, ok := inv.hosts[group]; !ok {
+		inv.hosts[group] = nil
+	}
+}
+
+// addHost adds a host pattern of an inventory to group, or to the ungrouped hosts when
+// group is empty or all. A trailing port, like in 'db.example.com:5309', is ignored.
+func (inv *ansibleInventory) addHost(group, host string) error {
+	if i := strings.LastIndexByte(host, ':'); i > strings.LastIndexByte(host, ']') {
+		if _, err := strconv.ParseUint(host[i+1:], 10, 16); err == nil {
+			host = host[:i]
+		}
+	}
+	pattern, err := FromAnsible(host)
+	if err != nil {
+		return err
+	}
+	if group == "" || group == "all" {
+		inv.ungrouped = append(inv.ungrouped, pattern)
+	} else {
+		inv.hosts[group] = append(inv.hosts[group], pattern)
+	}
+	return nil
+}
+
+// groups returns the pattern of each group of inv.
+func (inv *ansibleInventory) groups() (map[string]string, error) {
+	groups := make(map[string]string, len(inv.hosts)+2)
+	all := &NodeSet{}
+	grouped := &NodeSet{}
+	for name, hosts := range inv.hosts {
+		var patterns []string
+		for _, host := range hosts {
+			ns, err := Parse(host)
+			if err != nil {
+				return nil, fmt.Errorf("group %s, %w", name, err)
+			}
+			all = all.Union(ns)
+			grouped = grouped.Union(ns)
+			patterns = append(patterns, ns.String())
+		}
+		for _, child := range inv.children[name] {
+			patterns = append(patterns, "@"+child)
+		}
+		groups[name] = strings.Join(patterns, ",")
+	}
+	for _, host := range inv.ungrouped {
+		ns, err := Parse(host)
+		if err != nil {
+			return nil, err
+		}
+		all = all.Union(ns)
+	}
+	groups["all"] = all.String()
+	groups["ungrouped"] = all.Difference(grouped).String()
+	return groups, nil
+}
+
+// WriteAnsibleInventory writes groups to w as an Ansible inventory in the INI format,
+// a section per group in order of the group names, listing its hosts as returned by
+// ToAnsible.
+func WriteAnsibleInventory(w io.Writer, groups map[string]*NodeSet) error {
+	names := slices.Sorted(maps.Keys(groups))
+	for i, name := range names {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "[%s]\n", name); err != nil {
+			return err
+		}
+		for _, host := range ToAnsible(groups[name]) {
+			if _, err := fmt.Fprintln(w, host); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package nodeset
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFromAnsible(t *testing.T) {
+	tests := []struct {
+		host     string
+		want     string
+		wantKind error
+	}{
+		{host: "web[01:50].example.com", want: "web[01-50].example.com"},
+		{host: "web[01:50:2].example.com", want: "web[01-50/2].example.com"},
+		{host: "node[1:10]", want: "node[1-10]"},
+		{host: "db-[a:c].example.com", want: "db-a.example.com,db-b.example.com,db-c.example.com"},
+		{host: "db-[a:e:2]", want: "db-a,db-c,db-e"},
+		{host: "rack[a:b]-n[1:2]", want: "racka-n[1-2],rackb-n[1-2]"},
+		{host: "mail.example.com", want: "mail.example.com"},
+		{host: "web[01:5]", wantKind: ErrBadPadding},
+		{host: "web[5:1]", wantKind: ErrReversedRange},
+		{host: "web[c:a]", wantKind: ErrReversedRange},
+		{host: "web[1:5:0]", wantKind: ErrBadStep},
+		{host: "web[1-5]", wantKind: ErrBadValue},
+		{host: "web[1:5", wantKind: ErrUnbalancedBracket},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			got, err := FromAnsible(tt.host)
+			if tt.wantKind != nil {
+				if !errors.Is(err, tt.wantKind) {
+					t.Errorf("FromAnsible() error = %v, want %v", err, tt.wantKind)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromAnsible() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("FromAnsible() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToAnsible(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{pattern: "web[01-09].example.com", want: []string{"web[01:09].example.com"}},
+		{pattern: "node[1-3,7]", want: []string{"node[1:3]", "node7"}},
+		{pattern: "rack[1-2]n[1-2,4]", want: []string{"rack[1:2]n[1:2]", "rack[1:2]n4"}},
+		{pattern: "login", want: []string{"login"}},
+		{pattern: "db-[a-c,x]", want: []string{"db-[a:c]", "db-x"}},
+		{pattern: "oss[z-ab]", want: []string{"ossz", "ossaa", "ossab"}},
+		{pattern: "port[0x0e-0x10]", want: []string{"port0e", "port0f", "port10"}},
+		{pattern: "n[1-9/2]", want: []string{"n[1:9:2]"}},
+		{pattern: "n[02,05,08]", want: []string{"n[02:08:3]"}},
+		{pattern: "n[1,3,5-6]", want: []string{"n1", "n3", "n[5:6]"}},
+		{pattern: "n[1-11/2]&n[1-7]", want: []string{"n[1:7:2]"}},
+		{pattern: "db-[a,c,e]", want: []string{"db-[a:e:2]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			ns, err := Parse(tt.pattern)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got := ToAnsible(ns)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ToAnsible() = %v, want %v", got, tt.want)
+			}
+
+			// Converting back yields the same nodes.
+			var patterns []string
+			for _, host := range got {
+				pattern, err := FromAnsible(host)
+				if err != nil {
+					t.Fatalf("FromAnsible() error = %v", err)
+				}
+				patterns = append(patterns, pattern)
+			}
+			back, err := Parse(strings.Join(patterns, ","))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !back.Equal(ns) {
+				t.Errorf("FromAnsible(ToAnsible()) = %v, want %v", back, ns)
+			}
+		})
+	}
+}
+
+func TestLoadAnsibleInventory(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	ini := writeFile("hosts", `
+mail.example.com
+; comment
+
+[webservers]
+foo.example.com:5309
+www[01:03].example.com ansible_port=22
+
+[dbservers]
+db-[a:b].example.com
+
+[servers:children]
+webservers
+dbservers
+
+[webservers:vars]
+http_port=80
+`)
+	yml := writeFile("hosts.yml", `
+all:
+  hosts:
+    mail.example.com:
+  children:
+    servers:
+      children:
+        webservers:
+          hosts:
+            foo.example.com:
+            www[01:03].example.com:
+              ansible_port: 22
+        dbservers:
+          hosts:
+            db-[a:b].example.com:
+`)
+
+	want := map[string]string{
+		"@webservers": "www[01-03].example.com,foo.example.com",
+		"@dbservers":  "db-b.example.com,db-a.example.com",
+		"@servers":    "www[01-03].example.com,foo.example.com,db-b.example.com,db-a.example.com",
+		"@ungrouped":  "mail.example.com",
+		"@all":        "www[01-03].example.com,mail.example.com,foo.example.com,db-b.example.com,db-a.example.com",
+	}
+	for _, path := range []string{ini, yml} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			groups, err := LoadAnsibleInventory(path)
+			if err != nil {
+				t.Fatalf("LoadAnsibleInventory() error = %v", err)
+			}
+			r := &MapResolver{Default: "ansible", Map: map[string]map[string]string{"ansible": groups}}
+			for pattern, want := range want {
+				got, err := ParseWithOptions(pattern, Options{Resolver: r})
+				if err != nil {
+					t.Fatalf("ParseWithOptions() error = %v", err)
+				}
+				if got.String() != want {
+					t.Errorf("ParseWithOptions(%s) = %v, want %v", pattern, got, want)
+				}
+			}
+		})
+	}
+
+	bad := writeFile("bad", "[web]\nweb[1:2\n")
+	if _, err := LoadAnsibleInventory(bad); !errors.Is(err, ErrUnbalancedBracket) {
+		t.Errorf("LoadAnsibleInventory() error = %v, want ErrUnbalancedBracket", err)
+	}
+}
+
+func TestWriteAnsibleInventory(t *testing.T) {
+	groups := map[string]*NodeSet{
+		"web": NewNodeSet("web01", "web02", "web03", "web07"),
+		"db":  NewNodeSet("db1"),
+	}
+	var sb strings.Builder
+	if err := WriteAnsibleInventory(&sb, groups); err != nil {
+		t.Fatalf("WriteAnsibleInventory() error = %v", err)
+	}
+	want := "[db]\ndb1\n\n[web]\nweb[01:03]\nweb07\n"
+	if sb.String() != want {
+		t.Errorf("WriteAnsibleInventory() = %q, want %q", sb.String(), want)
+	}
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -17,26 +20,158 @@ func main() {
 	var expandSeperator string
 	var foldNodes bool
 	var foldSeperator string
+	var countNodes bool
+	var containsPattern string
+	var maxNodes uint64
+	var listGroups bool
+	var groupSource string
+	var gendersFile string
+	var slurmConf string
+	var slurmMode bool
+	var fromEnv bool
+	var slots bool
+	var hostfile string
+	var inventory string
+	var writeInventory bool
+	var regroup bool
+	var groupsOf string
+	var autostep int
+	var foldLetters bool
+	var foldHex bool
+	var foldAlternations bool
+	var query string
 
 	//flag.StringVarP(&pattern, "nodeset", "n", "", "nodeset pattern")
 	flag.BoolVarP(&expandNodeset, "expand", "e", false, "expand node sets to node list")
 	flag.StringVarP(&expandSeperator, "expandSeperator", "S", " ", "deliminator for expanded node list")
 	flag.BoolVarP(&foldNodes, "fold", "f", false, "fold node list into nodeset")
 	flag.StringVarP(&foldSeperator, "foldSeperator", "s", ",", "deliminator for fold node list")
+	flag.IntVar(&autostep, "autostep", 0, "fold arithmetic progressions of at least this many nodes into step ranges like node[1-99/2], 0 to disable")
+	flag.BoolVar(&foldLetters, "fold-letters", false, "fold nodes only differing by their last letter into alphabetic ranges like oss[a-c]")
+	flag.BoolVar(&foldHex, "fold-hex", false, "fold runs of hexadecimal digits into hexadecimal ranges like bmc-[0x0a1e-0x0a2f]")
+	flag.BoolVar(&foldAlternations, "fold-alternations", false, "factor folded patterns only differing by some text into alternations like node[1-4]-{eth,ib}")
+	flag.BoolVarP(&countNodes, "count", "c", false, "count the nodes of node sets")
+	flag.BoolVarP(&regroup, "regroup", "r", false, "fold node sets using the groups of the group source, like @rack[1-3],node[401-402]")
+	flag.BoolVar(&slurmMode, "slurm", false, "expand, fold and count Slurm hostlists like scontrol show hostnames and hostlistsorted")
+	flag.BoolVar(&fromEnv, "from-env", false, "add the nodes of the current batch job, from the Slurm, PBS, LSF or Cobalt environment")
+	flag.BoolVar(&slots, "slots", false, "count repeated nodes as slots, folding to patterns like node[1-4]*8 and expanding them back to repeated nodes")
+	flag.StringVar(&hostfile, "hostfile", "", "write an MPI hostfile of the nodes and their slots, in the openmpi or mpich format")
+	flag.StringVar(&containsPattern, "contains", "", "exit with status 0 if all node names given as arguments are in the node set pattern, 1 if not")
+
+	flag.StringVarP(&query, "query", "q", "", "fold the nodes matching a genders query like gpu=a100&&rack=3||login")
+
+	flag.StringVar(&groupsOf, "groups-of", "", "list the groups of every source holding nodes of a node set, and whether they hold all of them")
+	flag.BoolVarP(&listGroups, "list", "l", false, "list the groups of the group source")
+	flag.StringVar(&groupSource, "groupsource", "", "group source of group references without a source, and of --list")
+	flag.StringVar(&gendersFile, "genders", nodeset.DefaultGendersFile, "genders file providing the genders group source and --query")
+	flag.StringVar(&slurmConf, "slurmconf", nodeset.DefaultSlurmConf(), "slurm.conf file providing the partition and feature group sources")
+	flag.StringVar(&inventory, "inventory", "", "Ansible inventory file providing the ansible group source")
+	flag.BoolVar(&writeInventory, "ansible-inventory", false, "write the groups of the group source as an Ansible inventory")
+	flag.Uint64Var(&maxNodes, "max-nodes", 0, "maximum number of nodes a node set may contain, 0 for no limit")
 
 	flag.Parse()
 
-	if !expandNodeset && !foldNodes {
+	modes := 0
+	for _, mode := range []bool{expandNodeset, foldNodes, countNodes, regroup, containsPattern != "", listGroups, query != "", hostfile != "", writeInventory, groupsOf != ""} {
+		if mode {
+			modes++
+		}
+	}
+
+	if modes == 0 {
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	if expandNodeset && foldNodes {
-		fmt.Println("Specifying expand and fold at the same time is unsupported.")
+	if modes > 1 {
+		fmt.Println("Specifying more than one of expand, fold, count, regroup, contains, query, hostfile, ansible-inventory, groups-of and list at the same time is unsupported.")
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	// The genders file and the group sources are only loaded when used, so plain
+	// patterns don't depend on them being readable.
+	var genders *nodeset.Genders
+	loadedGenders := func() *nodeset.Genders {
+		if genders == nil {
+			var err error
+			if genders, err = loadGenders(gendersFile); err != nil {
+				fmt.Printf("Error loading genders, %v.\n", err)
+				os.Exit(1)
+			}
+		}
+		return genders
+	}
+	var resolver nodeset.GroupResolver
+	loadedGroups := func() nodeset.GroupResolver {
+		if resolver == nil {
+			var err error
+			if resolver, err = loadGroups(groupSource, loadedGenders(), slurmConf, inventory); err != nil {
+				fmt.Printf("Error loading groups, %v.\n", err)
+				os.Exit(1)
+			}
+		}
+		return resolver
+	}
+	opts := nodeset.Options{MaxNodes: maxNodes, Autostep: autostep, FoldLetters: foldLetters, FoldHex: foldHex, FoldAlternations: foldAlternations}
+
+	if listGroups {
+		groups, err := loadedGroups().Groups("")
+		if err != nil {
+			fmt.Printf("Error listing groups, %v.\n", err)
+			os.Exit(1)
+		}
+		for _, group := range groups {
+			fmt.Printf("@%s\n", group)
+		}
+		os.Exit(0)
+	}
+
+	if groupsOf != "" {
+		opts.Resolver = loadedGroups()
+		set, err := nodeset.ParseWithOptions(groupsOf, opts)
+		if err != nil {
+			printParseError(err)
+			os.Exit(1)
+		}
+		matches, err := nodeset.GroupsOf(set, opts.Resolver)
+		if err != nil {
+			fmt.Printf("Error looking up groups, %v.\n", err)
+			os.Exit(1)
+		}
+		for _, m := range matches {
+			if m.Full {
+				fmt.Printf("@%s:%s\tfull\n", m.Source, m.Group)
+			} else {
+				fmt.Printf("@%s:%s\tpartial\t%s\n", m.Source, m.Group, m.Nodes)
+			}
+		}
+		os.Exit(0)
+	}
+
+	if writeInventory {
+		opts.Resolver = loadedGroups()
+		groups, err := opts.Resolver.Groups("")
+		if err != nil {
+			fmt.Printf("Error listing groups, %v.\n", err)
+			os.Exit(1)
+		}
+		sets := make(map[string]*nodeset.NodeSet, len(groups))
+		for _, group := range groups {
+			set, err := nodeset.ParseWithOptions("@"+group, opts)
+			if err != nil {
+				printParseError(err)
+				os.Exit(1)
+			}
+			sets[group] = set
+		}
+		if err := nodeset.WriteAnsibleInventory(os.Stdout, sets); err != nil {
+			fmt.Printf("Error writing inventory, %v.\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// Attempt to interpret escape sequences, if any
 	if interpreted, err := strconv.Unquote(`"` + expandSeperator + `"`); err == nil {
 		expandSeperator = interpreted
@@ -45,6 +180,45 @@ func main() {
 		foldSeperator = interpreted
 	}
 
+	if query != "" {
+		set, err := loadedGenders().Query(query)
+		if err != nil {
+			fmt.Printf("Error querying genders, %v.\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s\n", strings.Join(set.Fold(), foldSeperator))
+		os.Exit(0)
+	}
+
+	if containsPattern != "" {
+		if flag.NArg() == 0 {
+			fmt.Println("No node names given to check against the contains pattern.")
+			os.Exit(2)
+		}
+		contains := func(name string) (bool, error) { return nodeset.Contains(containsPattern, name) }
+		if strings.Contains(containsPattern, "@") {
+			// Group references need the pattern to be resolved first.
+			opts.Resolver = loadedGroups()
+			set, err := nodeset.ParseWithOptions(containsPattern, opts)
+			if err != nil {
+				printParseError(err)
+				os.Exit(2)
+			}
+			contains = func(name string) (bool, error) { return nodeset.NewNodeSet(name).IsSubset(set), nil }
+		}
+		for _, name := range flag.Args() {
+			ok, err := contains(name)
+			if err != nil {
+				printParseError(err)
+				os.Exit(2)
+			}
+			if !ok {
+				os.Exit(1)
+			}
+		}
+		os.Exit(0)
+	}
+
 	fi, err := os.Stdin.Stat()
 	if err != nil {
 		fmt.Println("Error checking if data is available via stdin")
@@ -58,28 +232,224 @@ func main() {
 		}
 	}
 
-	if expandNodeset {
-		for _, set := range flag.Args() {
-			for _, splitNodeset := range nodeset.SplitOnComma(set) {
-				printer := func(s string) error { fmt.Printf("%s%s", s, expandSeperator); return nil }
-				err := nodeset.Expand(splitNodeset, printer)
-				if err != nil {
-					fmt.Printf("Error expanding nodeset, %v.\n", err)
-					os.Exit(1)
-				}
+	inputs := flag.Args()
+	if len(stdinData) > 0 {
+		inputs = strings.Fields(string(stdinData))
+	}
+	if regroup || slices.ContainsFunc(inputs, func(input string) bool { return strings.Contains(input, "@") }) {
+		opts.Resolver = loadedGroups()
+	}
+
+	var jobNodes []string
+	if fromEnv {
+		jobNodes, err = nodeset.JobNodeNames()
+		if err != nil {
+			fmt.Printf("Error reading batch job nodes, %v.\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if slurmMode {
+		names := jobNodes
+		for _, input := range inputs {
+			err := nodeset.ExpandSlurmWithOptions(input, opts, func(s string) error { names = append(names, s); return nil })
+			if err != nil {
+				printParseError(err)
+				os.Exit(1)
+			}
+			if maxNodes > 0 && uint64(len(names)) > maxNodes {
+				fmt.Printf("Error parsing nodeset, hostlists contain %d nodes, more than the maximum of %d.\n", len(names), maxNodes)
+				os.Exit(1)
+			}
+		}
+		switch {
+		case expandNodeset:
+			fmt.Printf("%s%s", strings.Join(names, expandSeperator), expandSeperator)
+		case foldNodes:
+			fmt.Printf("%s\n", nodeset.FoldSlurm(names))
+		case countNodes:
+			fmt.Printf("%d\n", len(names))
+		}
+		os.Exit(0)
+	}
+
+	if slots || hostfile != "" {
+		cs := &nodeset.CountedSet{}
+		if len(inputs) > 0 {
+			cs, err = nodeset.ParseCountedWithOptions(strings.Join(inputs, ","), opts)
+			if err != nil {
+				printParseError(err)
+				os.Exit(1)
+			}
+		}
+		for _, name := range jobNodes {
+			cs.Add(name, 1)
+		}
+		switch {
+		case hostfile != "":
+			formats := map[string]nodeset.HostfileFormat{"openmpi": nodeset.OpenMPIHostfile, "mpich": nodeset.MPICHHostfile}
+			format, ok := formats[hostfile]
+			if !ok {
+				fmt.Printf("Unknown hostfile format %s, expected openmpi or mpich.\n", hostfile)
+				os.Exit(1)
+			}
+			if err := cs.WriteHostfile(os.Stdout, format); err != nil {
+				fmt.Printf("Error writing hostfile, %v.\n", err)
+				os.Exit(1)
 			}
+		case expandNodeset:
+			printer := func(s string) error { fmt.Printf("%s%s", s, expandSeperator); return nil }
+			if err := cs.Expand(printer); err != nil {
+				fmt.Printf("Error expanding nodeset, %v.\n", err)
+				os.Exit(1)
+			}
+		case foldNodes:
+			fmt.Printf("%s\n", strings.Join(cs.Fold(), foldSeperator))
+		case countNodes:
+			fmt.Printf("%d\n", cs.Len())
 		}
+		os.Exit(0)
 	}
 
 	if foldNodes {
-		results := []string{}
-		if len(stdinData) > 0 {
-			inputs := strings.Fields(string(stdinData))
-			results = nodeset.Fold(inputs)
+		// Fold the given node names as is, like the Fold function.
+		names := append(jobNodes, inputs...)
+		if maxNodes > 0 && uint64(len(names)) > maxNodes {
+			fmt.Printf("Error folding nodes, %d nodes given, more than the maximum of %d.\n", len(names), maxNodes)
+			os.Exit(1)
+		}
+		fmt.Printf("%s\n", strings.Join(nodeset.FoldWithOptions(names, opts), foldSeperator))
+		os.Exit(0)
+	}
+
+	set := nodeset.NewNodeSet(jobNodes...)
+	for _, input := range inputs {
+		ns, err := nodeset.ParseWithOptions(input, opts)
+		if err != nil {
+			printParseError(err)
+			os.Exit(1)
+		}
+		set = set.Union(ns)
+		if maxNodes > 0 && set.Len() > maxNodes {
+			fmt.Printf("Error parsing nodeset, node sets contain %d nodes, more than the maximum of %d.\n", set.Len(), maxNodes)
+			os.Exit(1)
+		}
+	}
+
+	if expandNodeset {
+		printer := func(s string) error { fmt.Printf("%s%s", s, expandSeperator); return nil }
+		if err := expand(set, jobNodes, inputs, printer); err != nil {
+			fmt.Printf("Error expanding nodeset, %v.\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if countNodes {
+		fmt.Printf("%d\n", set.Len())
+	}
 
-		} else {
-			results = nodeset.Fold(flag.Args())
+	if regroup {
+		regrouped, err := set.Regroup(opts.Resolver, "")
+		if err != nil {
+			fmt.Printf("Error regrouping nodeset, %v.\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s\n", regrouped)
+	}
+}
+
+// expand calls printer with the node names of the job nodes and the inputs, in the order
+// given and each pattern in the same order as the Expand function. Inputs using set
+// operators or group references are expanded from their node set instead.
+func expand(set *nodeset.NodeSet, jobNodes, inputs []string, printer func(s string) error) error {
+	for _, input := range inputs {
+		if strings.ContainsAny(input, "!&^@") {
+			return set.Expand(printer)
+		}
+	}
+	for _, name := range jobNodes {
+		if err := printer(name); err != nil {
+			return err
 		}
-		fmt.Printf("%s\n", strings.Join(results, foldSeperator))
 	}
+	for _, input := range inputs {
+		for _, pattern := range nodeset.SplitOnComma(input) {
+			if err := nodeset.Expand(pattern, printer); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// printParseError prints err, followed by the pattern and a caret pointing at the
+// position of the error for a *nodeset.ParseError.
+func printParseError(err error) {
+	fmt.Printf("Error parsing nodeset, %v.\n", err)
+	var pe *nodeset.ParseError
+	if errors.As(err, &pe) {
+		fmt.Printf("  %s\n  %s^\n", pe.Pattern, strings.Repeat(" ", pe.Offset))
+	}
+}
+
+// loadGroups returns the group resolver used for group references like @compute, reading
+// the default group files, the attributes of genders as the genders source, and the
+// partitions and features of the slurm.conf file when it exists. Sources of the group
+// files take precedence over those of slurm.conf. A non-empty inventory adds the groups
+// of an Ansible inventory as the ansible source. A non-empty source replaces the
+// default group source.
+func loadGroups(source string, genders *nodeset.Genders, slurmConf, inventory string) (nodeset.GroupResolver, error) {
+	resolver, err := nodeset.LoadGroupFiles(nodeset.DefaultGroupFiles()...)
+	if err != nil {
+		return nil, err
+	}
+	if inventory != "" {
+		groups, err := nodeset.LoadAnsibleInventory(inventory)
+		if err != nil {
+			return nil, err
+		}
+		resolver.Map["ansible"] = groups
+		if resolver.Default == "" {
+			resolver.Default = "ansible"
+		}
+	}
+	if _, err := os.Stat(slurmConf); err == nil {
+		slurm, err := nodeset.LoadSlurmConf(slurmConf)
+		if err != nil {
+			return nil, err
+		}
+		for name, groups := range slurm.Map {
+			if _, ok := resolver.Map[name]; !ok {
+				resolver.Map[name] = groups
+			}
+		}
+		if resolver.Default == "" {
+			resolver.Default = slurm.Default
+		}
+	}
+	if len(genders.Attributes()) > 0 {
+		resolver.Map["genders"] = genders.Groups()
+		if resolver.Default == "" {
+			resolver.Default = "genders"
+		}
+	}
+	if source != "" {
+		resolver.Default = source
+	}
+	if resolver.Default == "" {
+		// Without group files, group references resolve against an empty local source.
+		resolver.Default = "local"
+		resolver.Map["local"] = map[string]string{}
+	}
+	return resolver, nil
+}
+
+// loadGenders reads the genders file at path, returning an empty Genders when the file
+// doesn't exist.
+func loadGenders(path string) (*nodeset.Genders, error) {
+	genders, err := nodeset.LoadGenders(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nodeset.ParseGenders(strings.NewReader(""))
+	}
+	return genders, err
 }
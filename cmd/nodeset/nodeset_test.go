@@ -0,0 +1,158 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain runs main instead of the tests when the test binary is run by runNodeset.
+func TestMain(m *testing.M) {
+	if os.Getenv("NODESET_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runNodeset runs the nodeset command with args, and returns its output.
+func runNodeset(t *testing.T, args ...string) string {
+	t.Helper()
+	out, err := nodesetOutput(t, args...)
+	if err != nil {
+		t.Fatalf("nodeset %v error = %v, output %q", args, err, out)
+	}
+	return out
+}
+
+// nodesetOutput runs the nodeset command with args, without a genders file or a
+// slurm.conf unless given by args, and returns its output and exit error.
+func nodesetOutput(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	args = append([]string{"--genders", filepath.Join(dir, "genders"), "--slurmconf", filepath.Join(dir, "slurm.conf")}, args...)
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "NODESET_TEST_MAIN=1")
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "Names in the order given",
+			args: []string{"-e", "a1", "b1"},
+			want: "a1 b1 ",
+		},
+		{
+			name: "Patterns separated by comma in the order given",
+			args: []string{"-e", "a1,b1"},
+			want: "a1 b1 ",
+		},
+		{
+			name: "Alternation in the order of Expand",
+			args: []string{"-e", "n[1-2]-{ib,eth}"},
+			want: "n1-ib n1-eth n2-ib n2-eth ",
+		},
+		{
+			name: "Letters in ascending order",
+			args: []string{"-e", "x[a-c]"},
+			want: "xa xb xc ",
+		},
+		{
+			name: "Set operators",
+			args: []string{"-e", "n[1-3]!n2", "a1"},
+			want: "a1 n1 n3 ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runNodeset(t, tt.args...); got != tt.want {
+				t.Errorf("nodeset %v = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "Names",
+			args: []string{"-f", "n3", "n1", "n2"},
+			want: "n[1-3]\n",
+		},
+		{
+			name: "Names with letters",
+			args: []string{"-f", "--fold-letters", "ossa", "ossb", "n1"},
+			want: "oss[a-b],n1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runNodeset(t, tt.args...); got != tt.want {
+				t.Errorf("nodeset %v = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourcesOnlyLoadedWhenUsed(t *testing.T) {
+	dir := t.TempDir()
+	genders := filepath.Join(dir, "genders")
+	if err := os.WriteFile(genders, []byte("node1 a b c\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	slurmConf := filepath.Join(dir, "slurm.conf")
+	if err := os.WriteFile(slurmConf, []byte("Include missing.conf\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sources := []string{"--genders", genders, "--slurmconf", slurmConf}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Expand",
+			args: []string{"-e", "node[1-2]"},
+			want: "node1 node2 ",
+		},
+		{
+			name: "Fold",
+			args: []string{"-f", "node1", "node2"},
+			want: "node[1-2]\n",
+		},
+		{
+			name: "Count",
+			args: []string{"-c", "node[1-2]"},
+			want: "2\n",
+		},
+		{
+			name:    "Group reference",
+			args:    []string{"-e", "@compute"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nodesetOutput(t, append(sources, tt.args...)...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("nodeset %v error = %v, wantErr %v, output %q", tt.args, err, tt.wantErr, got)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("nodeset %v = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
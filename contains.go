@@ -0,0 +1,107 @@
+package nodeset
+
+import (
+	"strings"
+)
+
+// Contains reports whether the node name is part of a node set pattern, accepting the
+// same syntax as Parse. Membership is decided by matching the literal parts of the
+// pattern and checking the digits or letters of name against the ranges of each
+// bracket, including their steps and zero padding, without expanding the pattern.
+func Contains(pattern, name string) (bool, error) {
+	var result bool
+	err := splitOperators(pattern, func(op byte, operand string) error {
+		p, err := Compile(operand)
+		if err != nil {
+			return err
+		}
+		matched := p.Contains(name)
+		switch op {
+		case '!':
+			result = result && !matched
+		case '&':
+			result = result && matched
+		case '^':
+			result = result != matched
+		default:
+			result = result || matched
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return result, nil
+}
+
+// matchSegments reports whether name is one of the names produced by segments. Since
+// adjacent brackets, like [1-2][0-9], don't mark where the digits of one bracket end,
+// every possible split of the leading digits and letters of name is tried, and so is
+// every alternative of an alternation. The positions segments may end at are tracked
+// as a set, so each segment is only matched once from each position of name.
+func matchSegments(segments []segment, name string) bool {
+	starts := make([]bool, len(name)+1)
+	starts[0] = true
+	return matchPositions(segments, name, starts)[len(name)]
+}
+
+// matchPositions returns the positions of name where segments may end, when starting
+// at any of the positions set in starts.
+func matchPositions(segments []segment, name string, starts []bool) []bool {
+	current := starts
+	for _, seg := range segments {
+		next := make([]bool, len(name)+1)
+		switch {
+		case seg.alternatives != nil:
+			for _, alternative := range seg.alternatives {
+				for pos, ok := range matchPositions(alternative, name, current) {
+					next[pos] = next[pos] || ok
+				}
+			}
+		case seg.ranges == nil:
+			for pos, ok := range current {
+				if ok && strings.HasPrefix(name[pos:], seg.literal) {
+					next[pos+len(seg.literal)] = true
+				}
+			}
+		default:
+			for pos, ok := range current {
+				if !ok {
+					continue
+				}
+				n := pos
+				for n < len(name) && isAlnum(name[n]) {
+					n++
+				}
+				for end := pos + 1; end <= n; end++ {
+					if rangesContain(seg.ranges, name[pos:end]) {
+						next[end] = true
+					}
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// rangesContain reports whether the digits, or letters, are one of the formatted values
+// of ranges.
+func rangesContain(ranges []Range, digits string) bool {
+	for _, r := range ranges {
+		val, ok := r.Notation.parse(digits)
+		if !ok || val < r.Start || val > r.End || (val-r.Start)%r.Step != 0 {
+			continue
+		}
+		// Digits with a leading zero only match a range padded to the same length,
+		// otherwise the range must not pad values beyond the length of the digits.
+		if padding := digitPadding(digits); padding > 0 {
+			if r.Padding == padding {
+				return true
+			}
+		} else if r.Padding <= len(digits) {
+			return true
+		}
+	}
+	return false
+}
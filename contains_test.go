@@ -0,0 +1,222 @@
+package nodeset
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContains(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		node    string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:    "No range",
+			pattern: "node1",
+			node:    "node1",
+			want:    true,
+		},
+		{
+			name:    "Padded single value",
+			pattern: "node[01,05-07]",
+			node:    "node01",
+			want:    true,
+		},
+		{
+			name:    "Padded single value, unpadded node",
+			pattern: "node[01,05-07]",
+			node:    "node1",
+			want:    false,
+		},
+		{
+			name:    "In range",
+			pattern: "node[1-100]",
+			node:    "node42",
+			want:    true,
+		},
+		{
+			name:    "Outside of range",
+			pattern: "node[1-100]",
+			node:    "node101",
+		},
+		{
+			name:    "Literal suffix mismatch",
+			pattern: "node[1-10]-ib",
+			node:    "node1-eth",
+		},
+		{
+			name:    "Step range",
+			pattern: "node[1-9/2]",
+			node:    "node7",
+			want:    true,
+		},
+		{
+			name:    "Step range, value skipped",
+			pattern: "node[1-9/2]",
+			node:    "node8",
+		},
+		{
+			name:    "Zero padding",
+			pattern: "node[001-100]",
+			node:    "node042",
+			want:    true,
+		},
+		{
+			name:    "Alphabetic range",
+			pattern: "oss[a-h]-ib",
+			node:    "ossc-ib",
+			want:    true,
+		},
+		{
+			name:    "Alphabetic range, other case",
+			pattern: "oss[a-h]",
+			node:    "ossC",
+		},
+		{
+			name:    "Hexadecimal range",
+			pattern: "port[0x00-0x3f]",
+			node:    "port2a",
+			want:    true,
+		},
+		{
+			name:    "Hexadecimal range, other case",
+			pattern: "port[0x00-0x3f]",
+			node:    "port2A",
+		},
+		{
+			name:    "Hexadecimal range, width not kept",
+			pattern: "port[0x00-0x3f]",
+			node:    "porta",
+		},
+		{
+			name:    "Alternation",
+			pattern: "node[1-4]-{ib,eth}",
+			node:    "node3-eth",
+			want:    true,
+		},
+		{
+			name:    "Alternation, other suffix",
+			pattern: "node[1-4]-{ib,eth}",
+			node:    "node3-bmc",
+		},
+		{
+			name:    "Alphabetic range next to digits",
+			pattern: "sw[1-2][aa-az]",
+			node:    "sw2ak",
+			want:    true,
+		},
+		{
+			name:    "Zero padding, name not padded",
+			pattern: "node[001-100]",
+			node:    "node42",
+		},
+		{
+			name:    "Zero padding, value longer than padding",
+			pattern: "node[01-150]",
+			node:    "node120",
+			want:    true,
+		},
+		{
+			name:    "No zero padding, name padded",
+			pattern: "node[1-100]",
+			node:    "node042",
+		},
+		{
+			name:    "Adjacent ranges",
+			pattern: "n[1-2][5-15]",
+			node:    "n215",
+			want:    true,
+		},
+		{
+			name:    "Adjacent ranges, no split matches",
+			pattern: "n[1-2][5-15]",
+			node:    "n235",
+		},
+		{
+			name:    "Union",
+			pattern: "node[1-2],gpu[1-2]",
+			node:    "gpu2",
+			want:    true,
+		},
+		{
+			name:    "Difference",
+			pattern: "node[1-100]!node[13,42]",
+			node:    "node42",
+		},
+		{
+			name:    "Intersection",
+			pattern: "node[1-10]&node[5-20]",
+			node:    "node5",
+			want:    true,
+		},
+		{
+			name:    "Symmetric difference",
+			pattern: "node[1-10]^node[5-20]",
+			node:    "node5",
+		},
+		{
+			name:    "Value out of range",
+			pattern: "node[1-100]",
+			node:    "node99999999999999999999999",
+		},
+		{
+			name:    "Many adjacent brackets",
+			pattern: "n" + strings.Repeat("[0-999999999]", 16),
+			node:    "n" + strings.Repeat("1", 40),
+			want:    true,
+		},
+		{
+			name:    "Many adjacent brackets, name not matching",
+			pattern: "n" + strings.Repeat("[0-999999999]", 16),
+			node:    "n" + strings.Repeat("1", 40) + "x",
+		},
+		{
+			name:    "Invalid pattern",
+			pattern: "node[1-",
+			node:    "node1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Contains(tt.pattern, tt.node)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Contains() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Contains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainsMatchesExpand(t *testing.T) {
+	pattern := "r[1-3]{n[01-12/3,8-10],x[1-2]}"
+	expanded := make(map[string]struct{})
+	err := Expand(pattern, func(s string) error {
+		expanded[s] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+
+	err = Expand("r[0-4]{n,x}[0-13,00-13,000-013]", func(s string) error {
+		_, want := expanded[s]
+		got, err := Contains(pattern, s)
+		if err != nil {
+			t.Fatalf("Contains() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("Contains(%s) = %v, want %v", s, got, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+}
@@ -0,0 +1,399 @@
+package nodeset
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Count returns the number of nodes of a node pattern like 'rack[1-48]node[001-128]'.
+// The count is calculated from the ranges of the pattern, without expanding it, so
+// patterns like 'id[1-10000000000]' are counted as quickly as 'id[1-2]'. Names produced
+// more than once by the pattern are only counted once, see Pattern.Count. Like Contains,
+// Count accepts the same syntax as Parse, patterns with operators like
+// 'node[1-10]!node2' are counted through their NodeSet.
+func Count(pattern string) (uint64, error) {
+	operands := 0
+	err := splitOperators(pattern, func(op byte, operand string) error {
+		operands++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if operands > 1 {
+		ns, err := Parse(pattern)
+		if err != nil {
+			return 0, err
+		}
+		n := ns.Len()
+		if n == math.MaxUint64 {
+			return 0, fmt.Errorf("pattern %s, contains more nodes than can be counted", pattern)
+		}
+		return n, nil
+	}
+
+	p, err := Compile(pattern)
+	if err != nil {
+		return 0, err
+	}
+	return p.Count()
+}
+
+// countSegments returns the number of distinct names produced by segments of pattern,
+// or a number greater than limit once they are known to be more than limit. When every
+// combination of the values of the segments results in a different name, the names are
+// counted as the product of the number of values of each segment. Otherwise, like for
+// n[1,11][1,11] resulting in n111 twice, the names are counted through the NodeSet of
+// the segments, or up to limit when the product is greater than limit.
+func countSegments(pattern string, segments []segment, limit uint64) (uint64, error) {
+	total, overflow := uint64(1), false
+	for _, seg := range segments {
+		var n uint64
+		switch {
+		case seg.alternatives != nil:
+			var err error
+			if n, err = countAlternation(pattern, seg, limit); err != nil {
+				return 0, err
+			}
+		case seg.ranges != nil:
+			var err error
+			if n, err = countRanges(seg.ranges); err != nil {
+				return 0, err
+			}
+		default:
+			continue
+		}
+		if n > limit {
+			// Each value of the segment results in a different name.
+			return n, nil
+		}
+		hi, lo := bits.Mul64(total, n)
+		overflow = overflow || hi != 0
+		total = lo
+	}
+
+	switch {
+	case !overflow && total <= limit:
+		if distinctProducts(segments) {
+			return total, nil
+		}
+	case limit < math.MaxUint64:
+		if distinctProducts(segments) {
+			return limit + 1, nil
+		}
+		return countNames(segments, limit), nil
+	case distinctProducts(segments):
+		return 0, fmt.Errorf("pattern %s, contains more nodes than can be counted", pattern)
+	}
+
+	ns, err := segmentsNodeSet(pattern, segments)
+	if err != nil {
+		return 0, err
+	}
+	n := ns.Len()
+	if n == math.MaxUint64 {
+		return 0, fmt.Errorf("pattern %s, contains more nodes than can be counted", pattern)
+	}
+	return n, nil
+}
+
+// countNames returns the number of distinct names produced by segments, visiting them
+// until more than limit have been seen, in which case limit+1 is returned.
+func countNames(segments []segment, limit uint64) uint64 {
+	seen := make(map[string]struct{})
+	c := newProductCursor(segments)
+	for name, ok := c.next(); ok; name, ok = c.next() {
+		seen[name] = struct{}{}
+		if uint64(len(seen)) > limit {
+			break
+		}
+	}
+	return uint64(len(seen))
+}
+
+// countAlternation returns the number of distinct names produced by the alternatives
+// of an alternation segment of pattern, or a number greater than limit once they are
+// known to be more than limit. Each alternative is counted on its own, less the names
+// also produced by earlier alternatives, which are only worked out through NodeSets for
+// alternatives that may produce the same names, see mayOverlap.
+func countAlternation(pattern string, seg segment, limit uint64) (uint64, error) {
+	var total uint64
+	for i, alternative := range seg.alternatives {
+		n, err := countSegments(pattern, alternative, limit)
+		if err != nil {
+			return 0, err
+		}
+		if n > limit {
+			return n, nil
+		}
+
+		var earlier *NodeSet
+		for _, other := range seg.alternatives[:i] {
+			if !mayOverlap(alternative, other) {
+				continue
+			}
+			ns, err := segmentsNodeSet(pattern, other)
+			if err != nil {
+				return 0, err
+			}
+			earlier = earlier.Union(ns)
+		}
+		if earlier != nil {
+			ns, err := segmentsNodeSet(pattern, alternative)
+			if err != nil {
+				return 0, err
+			}
+			n -= ns.Intersection(earlier).Len()
+		}
+
+		var carry uint64
+		total, carry = bits.Add64(total, n, 0)
+		if carry != 0 {
+			if limit < math.MaxUint64 {
+				return limit + 1, nil
+			}
+			return 0, fmt.Errorf("pattern %s, contains more nodes than can be counted", pattern)
+		}
+		if total > limit {
+			return total, nil
+		}
+	}
+	return total, nil
+}
+
+// mayOverlap reports whether the segments a and b may produce the same names. They
+// can't when the literal text they start or end with differs, when they start with
+// different characters, or when their names have different fixed lengths.
+func mayOverlap(a, b []segment) bool {
+	prefix := func(segments []segment) string {
+		if len(segments) > 0 && segments[0].ranges == nil && segments[0].alternatives == nil {
+			return segments[0].literal
+		}
+		return ""
+	}
+	suffix := func(segments []segment) string {
+		if n := len(segments); n > 0 && segments[n-1].ranges == nil && segments[n-1].alternatives == nil {
+			return segments[n-1].literal
+		}
+		return ""
+	}
+	pa, pb := prefix(a), prefix(b)
+	if !strings.HasPrefix(pa, pb) && !strings.HasPrefix(pb, pa) {
+		return false
+	}
+	sa, sb := suffix(a), suffix(b)
+	if !strings.HasSuffix(sa, sb) && !strings.HasSuffix(sb, sa) {
+		return false
+	}
+	if !mayBeEmpty(a) && !mayBeEmpty(b) && !strings.ContainsAny(firstChars(a), firstChars(b)) {
+		return false
+	}
+	la, fixedA := segmentsLength(a)
+	lb, fixedB := segmentsLength(b)
+	return !fixedA || !fixedB || la == lb
+}
+
+// distinctProducts reports whether every combination of the values of segments results
+// in a different name. That's the case when the end of the value of each segment can be
+// told from the name, either because all of its values have the same length, or because
+// what follows it can't start with a character its values are written with.
+func distinctProducts(segments []segment) bool {
+	for i, seg := range segments {
+		if seg.ranges == nil && seg.alternatives == nil {
+			continue
+		}
+		if _, fixed := segmentsLength(segments[i : i+1]); fixed {
+			continue
+		}
+		if strings.ContainsAny(segmentsAlphabet(segments[i:i+1]), firstChars(segments[i+1:])) {
+			return false
+		}
+	}
+	return true
+}
+
+// segmentsLength returns the length of the names produced by segments, false if they
+// don't all have the same length.
+func segmentsLength(segments []segment) (int, bool) {
+	total := 0
+	for _, seg := range segments {
+		switch {
+		case seg.alternatives != nil:
+			length := -1
+			for _, alternative := range seg.alternatives {
+				n, ok := segmentsLength(alternative)
+				if !ok || length >= 0 && n != length {
+					return 0, false
+				}
+				length = n
+			}
+			total += length
+		case seg.ranges != nil:
+			length := -1
+			for _, r := range seg.ranges {
+				for _, c := range r.canonical() {
+					f := c.valueFormat()
+					first, last := len(f.format(c.Start)), len(f.format(c.End))
+					if first != last || length >= 0 && first != length {
+						return 0, false
+					}
+					length = first
+				}
+			}
+			total += length
+		default:
+			total += len(seg.literal)
+		}
+	}
+	return total, true
+}
+
+// segmentsAlphabet returns the characters the names produced by segments may hold.
+func segmentsAlphabet(segments []segment) string {
+	var sb strings.Builder
+	for _, seg := range segments {
+		switch {
+		case seg.alternatives != nil:
+			for _, alternative := range seg.alternatives {
+				sb.WriteString(segmentsAlphabet(alternative))
+			}
+		case seg.ranges != nil:
+			for _, r := range seg.ranges {
+				sb.WriteString(r.Notation.alphabet())
+			}
+		default:
+			sb.WriteString(seg.literal)
+		}
+	}
+	return sb.String()
+}
+
+// firstChars returns the characters the names produced by segments may start with,
+// an empty string if segments only produce an empty name.
+func firstChars(segments []segment) string {
+	var sb strings.Builder
+	for _, seg := range segments {
+		switch {
+		case seg.alternatives != nil:
+			empty := false
+			for _, alternative := range seg.alternatives {
+				sb.WriteString(firstChars(alternative))
+				empty = empty || mayBeEmpty(alternative)
+			}
+			if !empty {
+				return sb.String()
+			}
+		case seg.ranges != nil:
+			sb.WriteString(segmentsAlphabet(segments[:1]))
+			return sb.String()
+		default:
+			_, size := utf8.DecodeRuneInString(seg.literal)
+			sb.WriteString(seg.literal[:size])
+			return sb.String()
+		}
+	}
+	return sb.String()
+}
+
+// mayBeEmpty reports whether segments may produce an empty name, through alternations
+// with an empty alternative.
+func mayBeEmpty(segments []segment) bool {
+	for _, seg := range segments {
+		if seg.alternatives == nil || !slices.ContainsFunc(seg.alternatives, mayBeEmpty) {
+			return false
+		}
+	}
+	return true
+}
+
+// countRanges returns the number of distinct values of the ranges of a bracket. Ranges
+// are kept as intervals with their step, so overlapping step ranges are counted
+// arithmetically without visiting their values.
+func countRanges(ranges []Range) (uint64, error) {
+	var total uint64
+	for _, values := range canonicalRanges(ranges) {
+		n, ok := values.len()
+		if !ok {
+			return 0, fmt.Errorf("range contains more values than can be counted")
+		}
+		var carry uint64
+		total, carry = bits.Add64(total, n, 0)
+		if carry != 0 {
+			return 0, fmt.Errorf("range contains more values than can be counted")
+		}
+	}
+	return total, nil
+}
+
+// canonicalRanges returns the distinct values of the ranges of a bracket as a rangeSet
+// per format, ranges with a step being kept as intervals with that step.
+func canonicalRanges(ranges []Range) map[valueFormat]rangeSet {
+	intervals := make(map[valueFormat][]interval)
+	for _, r := range ranges {
+		for _, c := range r.canonical() {
+			intervals[c.valueFormat()] = append(intervals[c.valueFormat()], newInterval(c.Start, c.End, c.Step))
+		}
+	}
+
+	result := make(map[valueFormat]rangeSet, len(intervals))
+	for f, ivs := range intervals {
+		result[f] = rangeSetOf(ivs)
+	}
+	return result
+}
+
+// last returns the last value of r, which may be less than the end of r when stepping
+// past it.
+func (r Range) last() uint64 {
+	return r.Start + (r.End-r.Start)/r.Step*r.Step
+}
+
+// canonical splits r into ranges where every value keeps the zero padding of its range.
+// A padded range like 01-150 only pads values below 10, values from 10 onwards have no
+// leading zero and are returned as a separate range without padding, likewise for
+// values from 0x10 onwards of a padded hexadecimal range like 0x01-0xff.
+func (r Range) canonical() []Range {
+	r.End = r.last()
+	if r.Padding == 0 {
+		return []Range{r}
+	}
+	bound, ok := pow(r.Notation.base(), r.Padding-1)
+	if !ok || r.End < bound {
+		return []Range{r}
+	}
+	if r.Start >= bound {
+		r.Padding = 0
+		return []Range{r}
+	}
+
+	lower := r
+	lower.End = r.Start + (bound-1-r.Start)/r.Step*r.Step
+	upper := r
+	upper.Padding = 0
+	upper.Start = lower.End + r.Step
+	if upper.Start > r.End || upper.Start < lower.End {
+		return []Range{lower}
+	}
+	return []Range{lower, upper}
+}
+
+// pow10 returns 10**n, false if it overflows an uint64.
+func pow10(n int) (uint64, bool) {
+	return pow(10, n)
+}
+
+// pow returns base**n, false if it overflows an uint64.
+func pow(base uint64, n int) (uint64, bool) {
+	result := uint64(1)
+	for i := 0; i < n; i++ {
+		if result > math.MaxUint64/base {
+			return 0, false
+		}
+		result *= base
+	}
+	return result, true
+}
@@ -0,0 +1,247 @@
+package nodeset
+
+import (
+	"testing"
+)
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    uint64
+		wantErr bool
+	}{
+		{
+			name:    "No range",
+			pattern: "node1",
+			want:    1,
+		},
+		{
+			name:    "Multiple ranges",
+			pattern: "rack[1-48]node[001-128]",
+			want:    6144,
+		},
+		{
+			name:    "Large range",
+			pattern: "id[1-10000000000]",
+			want:    10000000000,
+		},
+		{
+			name:    "Overlapping union ranges",
+			pattern: "node[1-10,5-15,20]",
+			want:    16,
+		},
+		{
+			name:    "Same value with several zero paddings",
+			pattern: "node[1,01,001,1]",
+			want:    3,
+		},
+		{
+			name:    "Step range",
+			pattern: "node[1-10/3]",
+			want:    4,
+		},
+		{
+			name:    "Alphabetic ranges",
+			pattern: "oss[a-z,aa-az,A-F]",
+			want:    58,
+		},
+		{
+			name:    "Hexadecimal ranges",
+			pattern: "port[0x00-0x3f,0x30-0x4f]",
+			want:    80,
+		},
+		{
+			name:    "Hexadecimal and decimal values",
+			pattern: "n[0x0f-0x10,10]",
+			wantErr: true,
+		},
+		{
+			name:    "Hexadecimal values without letters",
+			pattern: "port[0x0A,0x10]",
+			want:    2,
+		},
+		{
+			name:    "Alternation",
+			pattern: "node[1-4]-{ib,eth,bmc}",
+			want:    12,
+		},
+		{
+			name:    "Alternatives producing the same names",
+			pattern: "{n[1-10],n[5-20]}x",
+			want:    20,
+		},
+		{
+			name:    "Alternatives with large ranges",
+			pattern: "{a[0x0-0xffffffffff],b}",
+			want:    1099511627777,
+		},
+		{
+			name:    "Alternatives with adjacent large ranges",
+			pattern: "{a[1-1000000000][0-9],a[5-15]}",
+			want:    10000000005,
+		},
+		{
+			name:    "Letters and digits of the same value",
+			pattern: "node[0,a]",
+			want:    2,
+		},
+		{
+			name:    "Step range overlapping a range",
+			pattern: "node[1-100/2,50-60]",
+			want:    56,
+		},
+		{
+			name:    "Step ranges with the same step",
+			pattern: "node[1-9/2,5-15/2]",
+			want:    8,
+		},
+		{
+			name:    "Overlapping step ranges with different steps",
+			pattern: "node[0-30/2,0-30/3]",
+			want:    21,
+		},
+		{
+			name:    "Overlapping step ranges spanning every value",
+			pattern: "a[0-18446744073709551615/2,0-18446744073709551615/3]",
+			want:    12297829382473034411,
+		},
+		{
+			name:    "Padded and unpadded values",
+			pattern: "node[01-10,1-10]",
+			want:    19,
+		},
+		{
+			name:    "Large step range",
+			pattern: "node[0-18446744073709551615/2]",
+			want:    9223372036854775808,
+		},
+		{
+			name:    "Union of names",
+			pattern: "a,b",
+			want:    2,
+		},
+		{
+			name:    "Union of overlapping ranges",
+			pattern: "node[1-3],node[2-4]",
+			want:    4,
+		},
+		{
+			name:    "Difference",
+			pattern: "node[1-10]!node[2]",
+			want:    9,
+		},
+		{
+			name:    "Intersection and symmetric difference",
+			pattern: "node[1-10]&node[5-20]^node[1-6]",
+			want:    8,
+		},
+		{
+			name:    "Adjacent large ranges producing the same names",
+			pattern: "n[1-99999][1-99999]",
+			want:    9090909045,
+		},
+		{
+			name:    "Operator missing an operand",
+			pattern: "node[1-10]!",
+			wantErr: true,
+		},
+		{
+			name:    "Too many nodes",
+			pattern: "node[0-18446744073709551615]",
+			wantErr: true,
+		},
+		{
+			name:    "Product of ranges with too many nodes",
+			pattern: "a[1-4294967296]b[1-4294967296]",
+			wantErr: true,
+		},
+		{
+			name:    "Empty pattern",
+			pattern: "",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid range",
+			pattern: "node[2-1]",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Count(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Count() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Count() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountMatchesExpandAndLen(t *testing.T) {
+	patterns := []string{
+		"node[1-100/7,3-50/5,10-20]",
+		"node[1-4,3-9/3]x[01-12/4]",
+		"node[001-120/9,5-1000/11]",
+		"oss[a-z/3,x-ad,1-3]",
+		"{n[1-9],n[5-15]{,-ib},{a,b}[1-2]}",
+		"port[0x00-0xff/3,0x0f-0x20,0x00-0x10]",
+		"n[1,11][1,11]",
+		"n[1-2,12][2,22]",
+		"n[1,11]1",
+		"n{1,11}[1-11]",
+		"n[1-120][1-25]",
+	}
+	for _, pattern := range patterns {
+		t.Run(pattern, func(t *testing.T) {
+			seen := make(map[string]struct{})
+			err := Expand(pattern, func(s string) error {
+				seen[s] = struct{}{}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			got, err := Count(pattern)
+			if err != nil {
+				t.Fatalf("Count() error = %v", err)
+			}
+			if got != uint64(len(seen)) {
+				t.Errorf("Count() = %v, want %v", got, len(seen))
+			}
+			ns, err := Parse(pattern)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if ns.Len() != got {
+				t.Errorf("Len() = %v, want %v", ns.Len(), got)
+			}
+		})
+	}
+}
+
+func TestNodeSetLen(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    uint64
+	}{
+		{"node[1-10],node[5-15]", 15},
+		{"r[1-2]n[1-4]!r2n[3-6]", 6},
+		{"x100[1-2]", 2},
+		{"node[0-18446744073709551615]", 18446744073709551615},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			ns, err := Parse(tt.pattern)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := ns.Len(); got != tt.want {
+				t.Errorf("Len() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
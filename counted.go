@@ -0,0 +1,213 @@
+package nodeset
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CountedSet is a multiset of node names, recording how many times each node is
+// listed, like the slots of a node repeated in a PBS node file or MPI hostfile. The
+// zero value is an empty set.
+type CountedSet struct {
+	counts map[string]uint64
+}
+
+// NewCountedSet returns a CountedSet of the given node names, counting each name once
+// per time it is listed.
+func NewCountedSet(names ...string) *CountedSet {
+	cs := &CountedSet{}
+	for _, name := range names {
+		cs.Add(name, 1)
+	}
+	return cs
+}
+
+// ParseCounted returns the CountedSet of a counted pattern like 'node[1-4]*8,node5*4',
+// the form returned by CountedSet.Fold. Each comma separated pattern supports the same
+// syntax as Expand, optionally followed by '*' and the count of each of its nodes,
+// which defaults to 1. Counts of nodes listed by several patterns are added up.
+func ParseCounted(pattern string) (*CountedSet, error) {
+	return ParseCountedWithOptions(pattern, Options{})
+}
+
+// ParseCountedWithOptions is like ParseCounted, but checks the number of nodes of each
+// pattern, and of the set after adding it, against opts.MaxNodes. An error wrapping
+// ErrTooLarge is returned before the nodes of a pattern larger than allowed are added.
+func ParseCountedWithOptions(pattern string, opts Options) (*CountedSet, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+	cs := &CountedSet{}
+	offset := 0
+	for _, element := range SplitOnComma(pattern) {
+		nodes, count := element, uint64(1)
+		if i := strings.LastIndexByte(element, '*'); i >= 0 && !strings.ContainsAny(element[i:], "]}") {
+			n, err := strconv.ParseUint(element[i+1:], 10, 64)
+			if err != nil {
+				return nil, parseError(ErrBadValue, pattern, offset+i+1, element[i+1:], "count %s, is not an integer", element[i+1:])
+			}
+			nodes, count = element[:i], n
+		}
+		if nodes == "" {
+			return nil, parseError(ErrMissingOperand, pattern, offset, element, "count %s, is missing a node pattern", element)
+		}
+		p, err := Compile(nodes)
+		if err != nil {
+			return nil, relocate(err, pattern, offset)
+		}
+		if err := opts.check(p); err != nil {
+			return nil, err
+		}
+		for name := range p.All() {
+			cs.Add(name, count)
+		}
+		if opts.MaxNodes > 0 && uint64(len(cs.counts)) > opts.MaxNodes {
+			return nil, fmt.Errorf("pattern %s, contains more than the maximum of %d nodes: %w", pattern, opts.MaxNodes, ErrTooLarge)
+		}
+		offset += len(element) + 1
+	}
+	return cs, nil
+}
+
+// Add adds n to the count of name. Names with a count of 0 aren't part of the set.
+func (cs *CountedSet) Add(name string, n uint64) {
+	if n == 0 {
+		return
+	}
+	if cs.counts == nil {
+		cs.counts = make(map[string]uint64)
+	}
+	cs.counts[name] += n
+}
+
+// Count returns the number of times name is listed in cs, 0 if it isn't part of cs.
+func (cs *CountedSet) Count(name string) uint64 {
+	return cs.counts[name]
+}
+
+// Len returns the sum of the counts of every node of cs, the number of names Expand
+// calls iter with.
+func (cs *CountedSet) Len() uint64 {
+	var total uint64
+	for _, n := range cs.counts {
+		total += n
+	}
+	return total
+}
+
+// Nodes returns the nodes of cs, without their counts.
+func (cs *CountedSet) Nodes() *NodeSet {
+	return NewNodeSet(slices.Collect(maps.Keys(cs.counts))...)
+}
+
+// countedPattern is a folded pattern of nodes sharing the same count.
+type countedPattern struct {
+	pattern string
+	box     foldedBox
+	count   uint64
+}
+
+// patterns returns the folded patterns of cs, a pattern per folded box and count, in the
+// same order as the patterns of a NodeSet.
+func (cs *CountedSet) patterns() []countedPattern {
+	byCount := make(map[uint64][]string)
+	for name, n := range cs.counts {
+		byCount[n] = append(byCount[n], name)
+	}
+	var patterns []countedPattern
+	for n, names := range byCount {
+		for _, fb := range foldBoxes(NewNodeSet(names...).groups) {
+			patterns = append(patterns, countedPattern{pattern: fb.format(0), box: fb, count: n})
+		}
+	}
+	slices.SortFunc(patterns, func(x, y countedPattern) int {
+		if c := cmp.Compare(x.pattern, y.pattern); c != 0 {
+			return -c
+		}
+		return cmp.Compare(x.count, y.count)
+	})
+	return patterns
+}
+
+// Fold returns the folded patterns of cs, each followed by '*' and the count of each of
+// its nodes when the count isn't 1, for example node1, node1, node2, node2, node3 ->
+// node[1-2]*2, node3.
+func (cs *CountedSet) Fold() []string {
+	var output []string
+	for _, p := range cs.patterns() {
+		if p.count == 1 {
+			output = append(output, p.pattern)
+		} else {
+			output = append(output, fmt.Sprintf("%s*%d", p.pattern, p.count))
+		}
+	}
+	return output
+}
+
+// String returns the folded form of cs, the patterns returned by Fold seperated by comma.
+func (cs *CountedSet) String() string {
+	return strings.Join(cs.Fold(), ",")
+}
+
+// Expand calls iter with each node name of cs, repeated per its count, in the order of
+// the patterns returned by Fold. The result is a node list like a PBS node file.
+func (cs *CountedSet) Expand(iter func(s string) error) error {
+	if iter == nil {
+		return fmt.Errorf("iter function nil")
+	}
+	var err error
+	for _, p := range cs.patterns() {
+		p.box.names(func(name string) bool {
+			for range p.count {
+				if err = iter(name); err != nil {
+					return false
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HostfileFormat is the format of an MPI hostfile written by CountedSet.WriteHostfile.
+type HostfileFormat int
+
+const (
+	// OpenMPIHostfile lists a node per line followed by its slots, like 'node1 slots=8'.
+	OpenMPIHostfile HostfileFormat = iota
+	// MPICHHostfile lists a node per line followed by a colon and its slots, like 'node1:8'.
+	MPICHHostfile
+)
+
+// WriteHostfile writes cs to w as an MPI hostfile of the given format, a line per node
+// in the order of Expand, with the count of the node as its number of slots.
+func (cs *CountedSet) WriteHostfile(w io.Writer, format HostfileFormat) error {
+	var line string
+	switch format {
+	case OpenMPIHostfile:
+		line = "%s slots=%d\n"
+	case MPICHHostfile:
+		line = "%s:%d\n"
+	default:
+		return fmt.Errorf("unknown hostfile format %d", format)
+	}
+	var err error
+	for _, p := range cs.patterns() {
+		p.box.names(func(name string) bool {
+			_, err = fmt.Fprintf(w, line, name, p.count)
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package nodeset
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCountedSet(t *testing.T) {
+	var names []string
+	for _, name := range []string{"node1", "node2", "node3", "node4", "node5"} {
+		n := 8
+		if name == "node5" {
+			n = 4
+		}
+		for range n {
+			names = append(names, name)
+		}
+	}
+	cs := NewCountedSet(names...)
+
+	if got, want := cs.String(), "node[1-4]*8,node5*4"; got != want {
+		t.Errorf("String() = %v, want %v", got, want)
+	}
+	if got, want := cs.Len(), uint64(36); got != want {
+		t.Errorf("Len() = %v, want %v", got, want)
+	}
+	if got, want := cs.Count("node5"), uint64(4); got != want {
+		t.Errorf("Count() = %v, want %v", got, want)
+	}
+	if got, want := cs.Nodes().String(), "node[1-5]"; got != want {
+		t.Errorf("Nodes() = %v, want %v", got, want)
+	}
+
+	var expanded []string
+	if err := cs.Expand(func(s string) error { expanded = append(expanded, s); return nil }); err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+	if !reflect.DeepEqual(expanded, names) {
+		t.Errorf("Expand() = %v, want %v", expanded, names)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	if err := cs.Expand(func(s string) error { calls++; return stop }); !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Expand() error = %v after %d calls, want stop after 1 call", err, calls)
+	}
+}
+
+func TestParseCounted(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		want     string
+		wantKind error
+	}{
+		{name: "Counts", pattern: "node[1-4]*8,node5*4", want: "node[1-4]*8,node5*4"},
+		{name: "Default count", pattern: "node[1-2],node3*2", want: "node[1-2],node3*2"},
+		{name: "Counts added up", pattern: "node[1-4]*2,node[3-4]*2", want: "node[3-4]*4,node[1-2]*2"},
+		{name: "Zero count", pattern: "node[1-2]*0,node3", want: "node3"},
+		{name: "Invalid count", pattern: "node1*x", wantKind: ErrBadValue},
+		{name: "Missing pattern", pattern: "node1,*2", wantKind: ErrMissingOperand},
+		{name: "Invalid pattern", pattern: "node1,node[2-1]*2", wantKind: ErrReversedRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCounted(tt.pattern)
+			if tt.wantKind != nil {
+				if !errors.Is(err, tt.wantKind) {
+					t.Errorf("ParseCounted() error = %v, want %v", err, tt.wantKind)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCounted() error = %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("ParseCounted() = %v, want %v", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCountedWithOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		maxNodes uint64
+		want     string
+		wantKind error
+	}{
+		{name: "Within limit", pattern: "node[1-4]*8,node[3-4]*2", maxNodes: 4, want: "node[3-4]*10,node[1-2]*8"},
+		{name: "Pattern over limit", pattern: "node1,id[1-10000000000]*2", maxNodes: 1000, wantKind: ErrTooLarge},
+		{name: "Set over limit", pattern: "node[1-4]*8,node5", maxNodes: 4, wantKind: ErrTooLarge},
+		{name: "Invalid pattern", pattern: "node1,node[2-1]*2", maxNodes: 4, wantKind: ErrReversedRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCountedWithOptions(tt.pattern, Options{MaxNodes: tt.maxNodes})
+			if tt.wantKind != nil {
+				if !errors.Is(err, tt.wantKind) {
+					t.Errorf("ParseCountedWithOptions() error = %v, want %v", err, tt.wantKind)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCountedWithOptions() error = %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("ParseCountedWithOptions() = %v, want %v", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteHostfile(t *testing.T) {
+	cs, err := ParseCounted("node[1-2]*8,gpu1*4")
+	if err != nil {
+		t.Fatalf("ParseCounted() error = %v", err)
+	}
+	tests := []struct {
+		format HostfileFormat
+		want   string
+	}{
+		{format: OpenMPIHostfile, want: "node1 slots=8\nnode2 slots=8\ngpu1 slots=4\n"},
+		{format: MPICHHostfile, want: "node1:8\nnode2:8\ngpu1:4\n"},
+	}
+	for _, tt := range tests {
+		var sb strings.Builder
+		if err := cs.WriteHostfile(&sb, tt.format); err != nil {
+			t.Fatalf("WriteHostfile() error = %v", err)
+		}
+		if sb.String() != tt.want {
+			t.Errorf("WriteHostfile(%d) = %q, want %q", tt.format, sb.String(), tt.want)
+		}
+	}
+	if err := cs.WriteHostfile(&strings.Builder{}, HostfileFormat(9)); err == nil {
+		t.Errorf("WriteHostfile() of unknown format error = nil, want error")
+	}
+}
@@ -0,0 +1,284 @@
+package nodeset
+
+import (
+	"container/heap"
+	"slices"
+	"strconv"
+)
+
+// rangeCursor iterates over the values of the ranges of a bracket in numeric order,
+// deduplicated, without materializing them. Values equal in number but formatted with
+// a different zero padding, like 01 and 001, are ordered by the length of their padding,
+// and digits come before letters.
+// Only the position of the cursor is kept, so memory use doesn't depend on the number
+// of values.
+type rangeCursor struct {
+	sources []*rangeSource
+	queue   sourceQueue
+	started bool
+	last    rangeSource // Value and format of the last returned value.
+}
+
+// rangeSource is an ordered list of non-overlapping ranges sharing the same format,
+// along with the position of the cursor within them.
+type rangeSource struct {
+	ranges []Range
+	index  int
+	value  uint64
+	format valueFormat
+}
+
+// newRangeCursor returns a rangeCursor over ranges. Ranges with a step of one are
+// merged as intervals per format, while each range with a larger step is iterated
+// separately and merged with the others as values are produced.
+func newRangeCursor(ranges []Range) *rangeCursor {
+	intervals := make(map[valueFormat][]interval)
+	var sources []*rangeSource
+	for _, r := range ranges {
+		for _, c := range r.canonical() {
+			if c.Step == 1 || c.Start == c.End {
+				intervals[c.valueFormat()] = append(intervals[c.valueFormat()], interval{lo: c.Start, hi: c.End})
+				continue
+			}
+			sources = append(sources, &rangeSource{ranges: []Range{c}, format: c.valueFormat()})
+		}
+	}
+	for f, ivs := range intervals {
+		source := &rangeSource{format: f}
+		for _, iv := range rangeSetOf(ivs) {
+			source.ranges = append(source.ranges, Range{Start: iv.lo, End: iv.hi, Step: 1, Padding: f.padding, Notation: f.notation})
+		}
+		sources = append(sources, source)
+	}
+
+	c := &rangeCursor{sources: sources}
+	c.reset()
+	return c
+}
+
+// reset moves the cursor back before the first value.
+func (c *rangeCursor) reset() {
+	c.queue = c.queue[:0]
+	for _, s := range c.sources {
+		s.index = 0
+		s.value = s.ranges[0].Start
+		c.queue = append(c.queue, s)
+	}
+	heap.Init(&c.queue)
+	c.started = false
+}
+
+// next returns the next value formatted with its format, false when all values have
+// been returned.
+func (c *rangeCursor) next() (string, bool) {
+	for len(c.queue) > 0 {
+		s := c.queue[0]
+		value, f := s.value, s.format
+		if s.advance() {
+			heap.Fix(&c.queue, 0)
+		} else {
+			heap.Pop(&c.queue)
+		}
+
+		if c.started && value == c.last.value && f == c.last.format {
+			continue
+		}
+		c.started = true
+		c.last.value, c.last.format = value, f
+		return f.format(value), true
+	}
+	return "", false
+}
+
+// advance moves s to its next value, false if s has no more values.
+func (s *rangeSource) advance() bool {
+	r := s.ranges[s.index]
+	if r.End-s.value >= r.Step {
+		s.value += r.Step
+		return true
+	}
+	s.index++
+	if s.index == len(s.ranges) {
+		return false
+	}
+	s.value = s.ranges[s.index].Start
+	return true
+}
+
+// formatPadded returns v formatted in base 10 and zero padded to padding.
+func formatPadded(v uint64, padding int) string {
+	return zeroPad(strconv.FormatUint(v, 10), padding)
+}
+
+// zeroPad returns digits with leading zeros up to a length of padding.
+func zeroPad(digits string, padding int) string {
+	if len(digits) >= padding {
+		return digits
+	}
+	buf := make([]byte, padding)
+	n := copy(buf[padding-len(digits):], digits)
+	for i := 0; i < padding-n; i++ {
+		buf[i] = '0'
+	}
+	return string(buf)
+}
+
+// sourceQueue is a min-heap of rangeSources ordered by their notation, current value
+// and padding.
+type sourceQueue []*rangeSource
+
+func (q sourceQueue) Len() int { return len(q) }
+
+func (q sourceQueue) Less(i, j int) bool {
+	if q[i].format.notation != q[j].format.notation {
+		return q[i].format.notation < q[j].format.notation
+	}
+	if q[i].value != q[j].value {
+		return q[i].value < q[j].value
+	}
+	return q[i].format.padding < q[j].format.padding
+}
+
+func (q sourceQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *sourceQueue) Push(x any) { *q = append(*q, x.(*rangeSource)) }
+
+func (q *sourceQueue) Pop() any {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
+
+// valueCursor iterates over the values of a bracket or alternation segment.
+type valueCursor interface {
+	// next returns the next value, false when all values have been returned.
+	next() (string, bool)
+	// reset moves the cursor back before the first value.
+	reset()
+}
+
+// productCursor iterates over the Cartesian product of the segments of a pattern, like
+// an odometer where the last bracket changes fastest.
+type productCursor struct {
+	segments []segment
+	cursors  []valueCursor // nil for literal segments
+	values   []string
+	started  bool
+}
+
+func newProductCursor(segments []segment) *productCursor {
+	p := &productCursor{
+		segments: segments,
+		cursors:  make([]valueCursor, len(segments)),
+		values:   make([]string, len(segments)),
+	}
+	for i, seg := range segments {
+		switch {
+		case seg.alternatives != nil:
+			p.cursors[i] = newAlternationCursor(seg.alternatives)
+		case seg.ranges != nil:
+			p.cursors[i] = newRangeCursor(seg.ranges)
+		default:
+			p.values[i] = seg.literal
+		}
+	}
+	p.reset()
+	return p
+}
+
+// reset moves the cursor back before the first name.
+func (p *productCursor) reset() {
+	for i, c := range p.cursors {
+		if c != nil {
+			c.reset()
+			p.values[i], _ = c.next()
+		}
+	}
+	p.started = false
+}
+
+// next returns the next name of the product, false when all names have been returned.
+func (p *productCursor) next() (string, bool) {
+	if !p.started {
+		p.started = true
+		return p.name(), true
+	}
+	for j := len(p.cursors) - 1; j >= 0; j-- {
+		c := p.cursors[j]
+		if c == nil {
+			continue
+		}
+		if v, ok := c.next(); ok {
+			p.values[j] = v
+			return p.name(), true
+		}
+		c.reset()
+		p.values[j], _ = c.next()
+	}
+	return "", false
+}
+
+// name returns the current name of the product.
+func (p *productCursor) name() string {
+	n := 0
+	for _, v := range p.values {
+		n += len(v)
+	}
+	buf := make([]byte, 0, n)
+	for _, v := range p.values {
+		buf = append(buf, v...)
+	}
+	return string(buf)
+}
+
+// alternationCursor iterates over the names of each alternative of a brace alternation
+// in order, skipping names already returned for an earlier alternative.
+type alternationCursor struct {
+	alternatives [][]segment
+	cursors      []*productCursor
+	index        int
+}
+
+func newAlternationCursor(alternatives [][]segment) *alternationCursor {
+	c := &alternationCursor{alternatives: alternatives}
+	for _, segments := range alternatives {
+		c.cursors = append(c.cursors, newProductCursor(segments))
+	}
+	return c
+}
+
+// reset moves the cursor back before the first name.
+func (c *alternationCursor) reset() {
+	for _, p := range c.cursors {
+		p.reset()
+	}
+	c.index = 0
+}
+
+// next returns the next name, false when the names of all alternatives have been returned.
+func (c *alternationCursor) next() (string, bool) {
+	for c.index < len(c.cursors) {
+		name, ok := c.cursors[c.index].next()
+		if !ok {
+			c.index++
+			continue
+		}
+		if !slices.ContainsFunc(c.alternatives[:c.index], func(segments []segment) bool {
+			return matchSegments(segments, name)
+		}) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// rangeValues returns every value of ranges as a formatted string, deduplicated and numeric sorted.
+func rangeValues(ranges []Range) []string {
+	var values []string
+	c := newRangeCursor(ranges)
+	for v, ok := c.next(); ok; v, ok = c.next() {
+		values = append(values, v)
+	}
+	return slices.Clip(values)
+}
@@ -0,0 +1,60 @@
+package nodeset
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRangeCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges []Range
+		want   []string
+	}{
+		{
+			name:   "Single range",
+			ranges: []Range{{Start: 1, End: 3, Step: 1}},
+			want:   []string{"1", "2", "3"},
+		},
+		{
+			name:   "Overlapping ranges",
+			ranges: []Range{{Start: 5, End: 7, Step: 1}, {Start: 1, End: 6, Step: 1}},
+			want:   []string{"1", "2", "3", "4", "5", "6", "7"},
+		},
+		{
+			name:   "Overlapping steps",
+			ranges: []Range{{Start: 0, End: 10, Step: 2}, {Start: 0, End: 10, Step: 3}, {Start: 4, End: 5, Step: 1}},
+			want:   []string{"0", "2", "3", "4", "5", "6", "8", "9", "10"},
+		},
+		{
+			name:   "Mixed padding",
+			ranges: []Range{{Start: 1, End: 2, Step: 1, Padding: 2}, {Start: 1, End: 3, Step: 1, Padding: 3}},
+			want:   []string{"01", "001", "02", "002", "003"},
+		},
+		{
+			name:   "Padding split",
+			ranges: []Range{{Start: 8, End: 11, Step: 1, Padding: 2}, {Start: 10, End: 10, Step: 1}},
+			want:   []string{"08", "09", "10", "11"},
+		},
+		{
+			name:   "Last uint64 values",
+			ranges: []Range{{Start: 18446744073709551613, End: 18446744073709551615, Step: 2}},
+			want:   []string{"18446744073709551613", "18446744073709551615"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newRangeCursor(tt.ranges)
+			for pass := 0; pass < 2; pass++ {
+				var got []string
+				for v, ok := c.next(); ok; v, ok = c.next() {
+					got = append(got, v)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("next() pass %d = %v, want %v", pass, got, tt.want)
+				}
+				c.reset()
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package nodeset
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of parse errors, returned as the Kind of a *ParseError and usable with errors.Is.
+var (
+	ErrNestedBracket     = errors.New("nested bracket")
+	ErrUnbalancedBracket = errors.New("unbalanced bracket")
+	ErrBadValue          = errors.New("value is not an integer")
+	ErrBadStep           = errors.New("invalid step")
+	ErrReversedRange     = errors.New("range start is greater than its end")
+	ErrBadPadding        = errors.New("inconsistent zero padding")
+	ErrMissingOperand    = errors.New("operator is missing an operand")
+	ErrOutOfRange        = errors.New("digits are out of range")
+)
+
+// ParseError describes a problem with a node set pattern, and where in the pattern it was found.
+type ParseError struct {
+	Pattern string // Pattern being parsed.
+	Offset  int    // Byte offset of the problem within Pattern.
+	Token   string // Part of Pattern the problem was found in.
+	Kind    error  // One of the Err kinds above.
+
+	msg string
+}
+
+func (e *ParseError) Error() string {
+	msg := e.msg
+	if msg == "" {
+		msg = e.Kind.Error()
+	}
+	return fmt.Sprintf("pattern %s, offset %d, %s", e.Pattern, e.Offset, msg)
+}
+
+// Unwrap returns the kind of e, so errors.Is(err, ErrBadStep) matches a *ParseError of that kind.
+func (e *ParseError) Unwrap() error {
+	return e.Kind
+}
+
+// parseError returns a *ParseError of kind found in token at offset of pattern, with a
+// message formatted like fmt.Sprintf.
+func parseError(kind error, pattern string, offset int, token string, format string, a ...any) *ParseError {
+	return &ParseError{Pattern: pattern, Offset: offset, Token: token, Kind: kind, msg: fmt.Sprintf(format, a...)}
+}
+
+// relocate returns err with the position of a *ParseError found while parsing a part of
+// pattern starting at offset, adjusted to be relative to pattern. Other errors, including
+// wrapped parse errors of patterns resolved from groups, are returned as is.
+func relocate(err error, pattern string, offset int) error {
+	pe, ok := err.(*ParseError)
+	if !ok {
+		return err
+	}
+	return &ParseError{Pattern: pattern, Offset: pe.Offset + offset, Token: pe.Token, Kind: pe.Kind, msg: pe.msg}
+}
@@ -0,0 +1,130 @@
+package nodeset
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		name       string
+		pattern    string
+		wantKind   error
+		wantOffset int
+		wantToken  string
+	}{
+		{
+			name:       "Nested left bracket",
+			pattern:    "node[1-[2]]",
+			wantKind:   ErrNestedBracket,
+			wantOffset: 7,
+			wantToken:  "[1-[",
+		},
+		{
+			name:       "Left bracket without right bracket",
+			pattern:    "rack1,node[1-2",
+			wantKind:   ErrUnbalancedBracket,
+			wantOffset: 10,
+			wantToken:  "[1-2",
+		},
+		{
+			name:       "Right bracket without left bracket",
+			pattern:    "node1-2]",
+			wantKind:   ErrUnbalancedBracket,
+			wantOffset: 7,
+			wantToken:  "]",
+		},
+		{
+			name:       "Step is not an integer",
+			pattern:    "node[1,2-10/x]",
+			wantKind:   ErrBadStep,
+			wantOffset: 12,
+			wantToken:  "x",
+		},
+		{
+			name:       "More than one step delineator",
+			pattern:    "node[1-10/2/3]",
+			wantKind:   ErrBadStep,
+			wantOffset: 11,
+			wantToken:  "/3",
+		},
+		{
+			name:       "Step without range",
+			pattern:    "node[1/2]",
+			wantKind:   ErrBadStep,
+			wantOffset: 6,
+			wantToken:  "/2",
+		},
+		{
+			name:       "Range end is not an integer",
+			pattern:    "rack[1-2]node[3-x]",
+			wantKind:   ErrBadValue,
+			wantOffset: 16,
+			wantToken:  "x",
+		},
+		{
+			name:       "More than one range delineator",
+			pattern:    "n[1-2-3]",
+			wantKind:   ErrBadValue,
+			wantOffset: 5,
+			wantToken:  "-3",
+		},
+		{
+			name:       "Hexadecimal and decimal values",
+			pattern:    "n[0x0f-0x10,10]",
+			wantKind:   ErrBadValue,
+			wantOffset: 12,
+			wantToken:  "10",
+		},
+		{
+			name:       "Reversed range",
+			pattern:    "node[1-2]!node[1,9-3]",
+			wantKind:   ErrReversedRange,
+			wantOffset: 17,
+			wantToken:  "9-3",
+		},
+		{
+			name:       "Inconsistent padding",
+			pattern:    "node[01-002]",
+			wantKind:   ErrBadPadding,
+			wantOffset: 8,
+			wantToken:  "002",
+		},
+		{
+			name:       "Missing right operand",
+			pattern:    "node[1-2]&",
+			wantKind:   ErrMissingOperand,
+			wantOffset: 9,
+			wantToken:  "&",
+		},
+		{
+			name:       "Missing left operand",
+			pattern:    "!node[1-2]",
+			wantKind:   ErrMissingOperand,
+			wantOffset: 0,
+			wantToken:  "!",
+		},
+		{
+			name:       "Adjacent digits out of range",
+			pattern:    "node1,x99999999999[1-99999999999]",
+			wantKind:   ErrOutOfRange,
+			wantOffset: 18,
+			wantToken:  "[1-99999999999]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.pattern)
+			if !errors.Is(err, tt.wantKind) {
+				t.Fatalf("Parse() error = %v, want kind %v", err, tt.wantKind)
+			}
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("Parse() error = %T, want *ParseError", err)
+			}
+			if pe.Pattern != tt.pattern || pe.Offset != tt.wantOffset || pe.Token != tt.wantToken {
+				t.Errorf("Parse() error at %q offset %d token %q, want %q offset %d token %q", pe.Pattern, pe.Offset, pe.Token, tt.pattern, tt.wantOffset, tt.wantToken)
+			}
+		})
+	}
+}
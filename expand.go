@@ -1,16 +1,14 @@
 package nodeset
 
 import (
-	"cmp"
 	"fmt"
-	"slices"
 	"strconv"
 	"strings"
 )
 
-// SplitOnComma will split the input string on commas except for when within square brackets.
-// Used for pre-processing input strings for Expand when such input has multiple node patterns
-// seperated by comma like 'node[1-2],node[5-6]'
+// SplitOnComma will split the input string on commas except for when within square brackets
+// or braces. Used for pre-processing input strings for Expand when such input has multiple
+// node patterns seperated by comma like 'node[1-2],node[5-6]'
 func SplitOnComma(s string) []string {
 	var result []string
 	var buffer strings.Builder
@@ -18,10 +16,10 @@ func SplitOnComma(s string) []string {
 
 	for _, char := range s {
 		switch char {
-		case '[':
+		case '[', '{':
 			inBrackets++
 			buffer.WriteRune(char)
-		case ']':
+		case ']', '}':
 			inBrackets--
 			buffer.WriteRune(char)
 		case ',':
@@ -49,6 +47,9 @@ func SplitOnComma(s string) []string {
 // Addition pattern syntax supported:
 // Union ranges - node[1-2,5-9]
 // Step ranges - node[1-4/2]
+// Alphabetic ranges - oss[a-h], rack[aa-az], sw[A-F]
+// Hexadecimal ranges - port[0x00-0x3f], bmc-[0x0A1E-0x0A2F]
+// Alternations - node[1-4]-{ib,eth,bmc}, {login,gpu[1-2]}-ib
 // The supplied iter function is called per Cartesian product.
 func Expand(pattern string, iter func(s string) error) error {
 	if pattern == "" {
@@ -57,25 +58,11 @@ func Expand(pattern string, iter func(s string) error) error {
 	if iter == nil {
 		return fmt.Errorf("iter function nil")
 	}
-	ranges, err := splitInput(pattern)
+	p, err := Compile(pattern)
 	if err != nil {
 		return err
 	}
-
-	// https://stackoverflow.com/a/29004530
-	lens := func(i int) int { return len(ranges[i]) }
-
-	for ix := make([]int, len(ranges)); ix[0] < lens(0); nextIndex(ix, lens) {
-		var r []string
-		for j, k := range ix {
-			r = append(r, ranges[j][k])
-		}
-		err := iter(strings.Join(r, ""))
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return p.Expand(iter)
 }
 
 // NextIndex sets ix to the lexicographically next value,
@@ -91,128 +78,280 @@ func nextIndex(ix []int, lens func(i int) int) {
 	}
 }
 
+// segment is a piece of a node pattern, either literal text, the ranges of a bracket
+// expression, or the alternatives of a brace alternation.
+type segment struct {
+	literal      string
+	ranges       []Range     // nil for literal and alternation segments
+	alternatives [][]segment // Segments of each alternative, nil for literal and bracket segments.
+	offset       int         // Byte offset of the segment within the pattern.
+}
+
 func splitInput(input string) ([][]string, error) {
-	var ranges [][]string
+	segments, err := splitPattern(input)
+	if err != nil {
+		return [][]string{}, err
+	}
+	return segmentValues(segments), nil
+}
+
+// segmentValues returns the literal of each literal segment, and every value of each
+// bracket and alternation segment, as a list of strings per segment.
+func segmentValues(segments []segment) [][]string {
+	ranges := make([][]string, len(segments))
+	for i, seg := range segments {
+		switch {
+		case seg.alternatives != nil:
+			c := newAlternationCursor(seg.alternatives)
+			for v, ok := c.next(); ok; v, ok = c.next() {
+				ranges[i] = append(ranges[i], v)
+			}
+		case seg.ranges == nil:
+			ranges[i] = []string{seg.literal}
+		default:
+			ranges[i] = rangeValues(seg.ranges)
+		}
+	}
+	return ranges
+}
+
+// splitPattern splits a node pattern into literal, bracket and alternation segments,
+// without expanding the ranges of the brackets.
+func splitPattern(input string) ([]segment, error) {
+	var segments []segment
 
-	for input != "" {
-		if input[0] == '[' {
-			end := 0
+	for pos := 0; pos < len(input); {
+		if input[pos] == '{' {
+			seg, end, err := splitAlternation(input, pos)
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, seg)
+			pos = end + 1
+		} else if input[pos] == '[' {
+			end := pos
 			for ; end < len(input) && input[end] != ']'; end++ {
-				if end != 0 && input[end] == '[' {
-					return [][]string{}, fmt.Errorf("input %s, contains a nested left bracket", input)
+				if end != pos && input[end] == '[' {
+					return nil, parseError(ErrNestedBracket, input, end, input[pos:end+1], "contains a nested left bracket")
 				}
 			}
-			if end == len(input) || input[end] != ']' {
-				return [][]string{}, fmt.Errorf("input %s, contains a left bracket without a right bracket", input)
+			if end == len(input) {
+				return nil, parseError(ErrUnbalancedBracket, input, pos, input[pos:], "contains a left bracket without a right bracket")
 			}
-			set, err := parseRange(input[:end+1])
+			ranges, err := parseRanges(input[pos : end+1])
 			if err != nil {
-				return [][]string{}, err
+				return nil, relocate(err, input, pos)
 			}
-			ranges = append(ranges, set)
-			input = input[end+1:]
+			segments = append(segments, segment{ranges: ranges, offset: pos})
+			pos = end + 1
 		} else {
-			end := 0
-			for ; end < len(input) && input[end] != '['; end++ {
+			end := pos
+			for ; end < len(input) && input[end] != '[' && input[end] != '{'; end++ {
 				if input[end] == ']' {
-					return [][]string{}, fmt.Errorf("input %s, contains a right bracket without a left bracket", input)
+					return nil, parseError(ErrUnbalancedBracket, input, end, "]", "contains a right bracket without a left bracket")
+				}
+				if input[end] == '}' {
+					return nil, parseError(ErrUnbalancedBracket, input, end, "}", "contains a right brace without a left brace")
 				}
 			}
 
-			ranges = append(ranges, []string{input[:end]})
-			input = input[end:]
+			segments = append(segments, segment{literal: input[pos:end], offset: pos})
+			pos = end
+		}
+	}
+	return segments, nil
+}
+
+// splitAlternation parses the brace alternation of input starting at pos, like
+// {ib,eth[0-1]}, returning its segment and the position of its right brace. Each
+// alternative is a node pattern of its own, that may hold brackets and alternations.
+func splitAlternation(input string, pos int) (segment, int, error) {
+	depth := 0
+	end := pos
+	for ; end < len(input); end++ {
+		if input[end] == '{' {
+			depth++
+		} else if input[end] == '}' {
+			depth--
+			if depth == 0 {
+				break
+			}
+		}
+	}
+	if end == len(input) {
+		return segment{}, 0, parseError(ErrUnbalancedBracket, input, pos, input[pos:], "contains a left brace without a right brace")
+	}
+	if end == pos+1 {
+		return segment{}, 0, parseError(ErrBadValue, input, pos, "{}", "contains an alternation without alternatives")
+	}
+
+	seg := segment{offset: pos}
+	offset := pos + 1
+	for _, alternative := range SplitOnComma(input[pos+1 : end]) {
+		segments, err := splitPattern(alternative)
+		if err != nil {
+			return segment{}, 0, relocate(err, input, offset)
+		}
+		shiftSegments(segments, offset)
+		seg.alternatives = append(seg.alternatives, segments)
+		offset += len(alternative) + 1
+	}
+	return seg, end, nil
+}
+
+// shiftSegments adds delta to the offset of segments, and of the segments of their
+// alternatives.
+func shiftSegments(segments []segment, delta int) {
+	for i := range segments {
+		segments[i].offset += delta
+		for _, alternative := range segments[i].alternatives {
+			shiftSegments(alternative, delta)
 		}
 	}
-	return ranges, nil
 }
 
 // parseRange takes a string in the form of [1], [1-2], or [1-4/2]
 // The returned range sets are deduplicated and numeric sorted.
 func parseRange(rangeStr string) ([]string, error) {
-	var rangeValues []string
+	ranges, err := parseRanges(rangeStr)
+	if err != nil {
+		return []string{}, err
+	}
+	return rangeValues(ranges), nil
+}
+
+// parseRanges takes a string in the form of [1], [1-2], or [1-4/2] and returns
+// its ranges without expanding them. A single value is returned as a range
+// starting and ending with the value.
+func parseRanges(rangeStr string) ([]Range, error) {
+	var ranges []Range
 
 	// Remove brackets from the range string
-	if len(rangeStr) > 1 && rangeStr[0] == '[' && rangeStr[len(rangeStr)-1] == ']' {
-		rangeStr = rangeStr[1 : len(rangeStr)-1]
-	} else {
-		return []string{}, fmt.Errorf("range [%s], is missing enclosing brackets", rangeStr)
+	if len(rangeStr) < 2 || rangeStr[0] != '[' || rangeStr[len(rangeStr)-1] != ']' {
+		return nil, parseError(ErrUnbalancedBracket, rangeStr, 0, rangeStr, "range [%s], is missing enclosing brackets", rangeStr)
 	}
 
-	// Split the range string by ','
-	for _, index := range strings.Split(rangeStr, ",") {
-		index, step, err := parseStep(index)
+	// Split the range string by ',', offset is the position of each element within rangeStr.
+	elements := strings.Split(rangeStr[1:len(rangeStr)-1], ",")
+	offsets := make([]int, len(elements))
+	offset := 1
+	for i, element := range elements {
+		r, err := parseElement(element)
 		if err != nil {
-			return []string{}, err
+			return nil, relocate(err, rangeStr, offset)
 		}
+		ranges = append(ranges, r)
+		offsets[i] = offset
+		offset += len(element) + 1
+	}
 
-		rangeSplit := strings.Split(index, "-")
-
-		if len(rangeSplit) == 1 {
-			if step != 0 {
-				return []string{}, fmt.Errorf("range [%s], contains a step without a start and stop range", index)
-			}
-			val, err := strconv.ParseUint(rangeSplit[0], 10, 64)
-			if err != nil {
-				return []string{}, fmt.Errorf("range [%s], contains a single value that is not an integer", index)
-			}
-			rangeValues = append(rangeValues, strconv.FormatUint(val, 10))
-		} else if len(rangeSplit) == 2 {
-			start, err := strconv.ParseUint(rangeSplit[0], 10, 64)
-			if err != nil {
-				return []string{}, fmt.Errorf("range [%s], start with a value that is not an integer", index)
-			}
-			end, err := strconv.ParseUint(rangeSplit[1], 10, 64)
-			if err != nil {
-				return []string{}, fmt.Errorf("range [%s], ends with a value that is not an integer", index)
+	// Hexadecimal elements without letters, like 0x10 in [0x0A,0x10], take the case of
+	// the other elements.
+	letters := func(i int) bool { return ranges[i].Notation.hex() && strings.ContainsAny(elements[i], "abcdefABCDEF") }
+	for i := range ranges {
+		if !letters(i) {
+			continue
+		}
+		for j := range ranges {
+			if ranges[j].Notation.hex() && !letters(j) {
+				ranges[j].Notation = ranges[i].Notation
 			}
+		}
+		break
+	}
 
-			if start > end {
-				return []string{}, fmt.Errorf("range [%s], starts with a value that is greater than the end value", index)
+	// Values written in notations sharing characters, like 10 and 0x10, could result in
+	// the same names.
+	for i, r := range ranges {
+		for _, o := range ranges[:i] {
+			if r.Notation != o.Notation && strings.ContainsAny(r.Notation.alphabet(), o.Notation.alphabet()) {
+				return nil, parseError(ErrBadValue, rangeStr, offsets[i], elements[i], "range [%s], mixes values written in notations that may result in the same names", rangeStr[1:len(rangeStr)-1])
 			}
+		}
+	}
+	return ranges, nil
+}
 
-			// If range start value has more than two characters and has a leading zero, assume that the output
-			// should be padded to the same length as the start value.
-			var padding int
-			if len(rangeSplit[0]) > 1 && rangeSplit[0][0] == '0' {
-				if len(rangeSplit[0]) > len(rangeSplit[1]) {
-					return []string{}, fmt.Errorf("range [%s], zero padding on start value greater than end value length", index)
-				}
-				if rangeSplit[1][0] == '0' && (len(rangeSplit[0]) != len(rangeSplit[1])) {
-					return []string{}, fmt.Errorf("range [%s], zero padding on end value must be same length as start value", index)
-				}
-				padding = len(rangeSplit[0])
-			}
+// parseElement parses a single element of a bracket, like 1, 1-2, 1-4/2, a-c or 0x0a-0x1f.
+func parseElement(element string) (Range, error) {
+	index, step, err := parseStep(element)
+	if err != nil {
+		return Range{}, err
+	}
 
-			// If step is its zero-value, default to incrementing by 1.
-			if step == 0 {
-				step = 1
-			}
+	rangeSplit := strings.Split(index, "-")
 
-			for i := start; i <= end; i += step {
-				rangeValues = append(rangeValues, fmt.Sprintf("%0*d", padding, i))
-			}
+	if len(rangeSplit) > 2 {
+		offset := len(rangeSplit[0]) + len(rangeSplit[1]) + 1
+		return Range{}, parseError(ErrBadValue, element, offset, index[offset:], "range [%s], contains more than one range delineator '-'", index)
+	}
+	if len(rangeSplit) == 1 {
+		if step != 0 {
+			return Range{}, parseError(ErrBadStep, element, len(index), element[len(index):], "range [%s], contains a step without a start and stop range", index)
+		}
+		val, notation, digits, ok := parseValue(rangeSplit[0])
+		if !ok {
+			return Range{}, parseError(ErrBadValue, element, 0, index, "range [%s], contains a single value that is not an integer or letters", index)
+		}
+		// Single values keep their zero padding, like 01 in [01,05-07].
+		return Range{Start: val, End: val, Step: 1, Padding: digitPadding(digits), Notation: notation}, nil
+	}
+	endOffset := len(rangeSplit[0]) + 1
+	start, notation, startDigits, ok := parseValue(rangeSplit[0])
+	if !ok {
+		return Range{}, parseError(ErrBadValue, element, 0, rangeSplit[0], "range [%s], start with a value that is not an integer or letters", index)
+	}
+	end, endNotation, endDigits, ok := parseValue(rangeSplit[1])
+	if !ok {
+		return Range{}, parseError(ErrBadValue, element, endOffset, rangeSplit[1], "range [%s], ends with a value that is not an integer or letters", index)
+	}
+	// Hexadecimal values without letters, like 0x00 in [0x00-0x3F], take the case of the other value.
+	if notation != endNotation && notation.hex() && endNotation.hex() {
+		if _, ok := UpperHex.parse(startDigits + endDigits); ok {
+			notation, endNotation = UpperHex, UpperHex
 		}
 	}
+	if notation != endNotation {
+		return Range{}, parseError(ErrBadValue, element, endOffset, rangeSplit[1], "range [%s], ends with a value not written like the start value", index)
+	}
+
+	if start > end {
+		return Range{}, parseError(ErrReversedRange, element, 0, index, "range [%s], starts with a value that is greater than the end value", index)
+	}
+
+	// If range start value has more than two characters and has a leading zero, assume that the output
+	// should be padded to the same length as the start value.
+	var padding int
+	if len(startDigits) > 1 && startDigits[0] == '0' {
+		if len(startDigits) > len(endDigits) {
+			return Range{}, parseError(ErrBadPadding, element, 0, index, "range [%s], zero padding on start value greater than end value length", index)
+		}
+		if endDigits[0] == '0' && (len(startDigits) != len(endDigits)) {
+			return Range{}, parseError(ErrBadPadding, element, endOffset, rangeSplit[1], "range [%s], zero padding on end value must be same length as start value", index)
+		}
+		padding = len(startDigits)
+	}
+
+	// If step is its zero-value, default to incrementing by 1.
+	if step == 0 {
+		step = 1
+	}
 
-	// Sort the values, safe to assume the strings are uint64 at this point
-	slices.SortStableFunc(rangeValues, func(a, b string) int {
-		an, _ := strconv.ParseUint(a, 10, 64)
-		bn, _ := strconv.ParseUint(b, 10, 64)
-		return cmp.Compare[uint64](an, bn)
-	})
-	return slices.Compact(rangeValues), nil
+	return Range{Start: start, End: end, Step: step, Padding: padding, Notation: notation}, nil
 }
 
 func parseStep(rangeStr string) (string, uint64, error) {
 	var step uint64
 	stepSplit := strings.Split(rangeStr, "/")
 	if len(stepSplit) > 2 {
-		return "", 0, fmt.Errorf("range [%s], contains more than one step delineator '/'", rangeStr)
+		offset := len(stepSplit[0]) + len(stepSplit[1]) + 1
+		return "", 0, parseError(ErrBadStep, rangeStr, offset, rangeStr[offset:], "range [%s], contains more than one step delineator '/'", rangeStr)
 	} else if len(stepSplit) == 2 {
 		var err error
 		step, err = strconv.ParseUint(stepSplit[1], 10, 64)
 		if err != nil {
-			return "", 0, fmt.Errorf("range [%s], contains a step that is not an integer", rangeStr)
+			offset := len(stepSplit[0]) + 1
+			return "", 0, parseError(ErrBadStep, rangeStr, offset, stepSplit[1], "range [%s], contains a step that is not an integer", rangeStr)
 		}
 	}
 	return stepSplit[0], step, nil
@@ -16,6 +16,7 @@ func TestSplitOnComma(t *testing.T) {
 		{"a[1,2],b[3,4],c", []string{"a[1,2]", "b[3,4]", "c"}},
 		{"[1,2],[3,4]", []string{"[1,2]", "[3,4]"}},
 		{"[1,2],3,4", []string{"[1,2]", "3", "4"}},
+		{"node{a,b},c", []string{"node{a,b}", "c"}},
 	}
 
 	for _, tc := range testCases {
@@ -65,6 +66,36 @@ func TestExpand(t *testing.T) {
 			args: args{pattern: "node[1-2,5-6]", iter: funcArg},
 			want: []string{"node1", "node2", "node5", "node6"},
 		},
+		{
+			name: "Alphabetic range",
+			args: args{pattern: "sw1[a-d]", iter: funcArg},
+			want: []string{"sw1a", "sw1b", "sw1c", "sw1d"},
+		},
+		{
+			name: "Multi-letter alphabetic range",
+			args: args{pattern: "rack[ay-bb]", iter: funcArg},
+			want: []string{"rackay", "rackaz", "rackba", "rackbb"},
+		},
+		{
+			name: "Hexadecimal range",
+			args: args{pattern: "bmc-[0x0a1e-0x0a21]", iter: funcArg},
+			want: []string{"bmc-0a1e", "bmc-0a1f", "bmc-0a20", "bmc-0a21"},
+		},
+		{
+			name: "Alternation",
+			args: args{pattern: "node[1-2]-{ib,eth,bmc}", iter: funcArg},
+			want: []string{"node1-ib", "node1-eth", "node1-bmc", "node2-ib", "node2-eth", "node2-bmc"},
+		},
+		{
+			name: "Nested alternations and brackets",
+			args: args{pattern: "{login,gpu[1-2]{,-ib}}", iter: funcArg},
+			want: []string{"login", "gpu1", "gpu1-ib", "gpu2", "gpu2-ib"},
+		},
+		{
+			name: "Alternatives producing the same names",
+			args: args{pattern: "{n[1-2],n[2-3]}", iter: funcArg},
+			want: []string{"n1", "n2", "n3"},
+		},
 		{
 			name:    "Empty pattern",
 			args:    args{pattern: "", iter: funcArg},
@@ -184,8 +215,32 @@ func Test_splitInput(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name:    "Single value range non-integer, passing error up from parseRange",
-			args:    args{input: "node[a]"},
+			name:    "Left brace but no right brace",
+			args:    args{input: "node{a,b"},
+			want:    [][]string{},
+			wantErr: true,
+		},
+		{
+			name:    "Right brace but no left brace",
+			args:    args{input: "node-a}"},
+			want:    [][]string{},
+			wantErr: true,
+		},
+		{
+			name:    "Empty alternation",
+			args:    args{input: "node{}"},
+			want:    [][]string{},
+			wantErr: true,
+		},
+		{
+			name:    "Alternative with an invalid bracket",
+			args:    args{input: "node{a,[1-}"},
+			want:    [][]string{},
+			wantErr: true,
+		},
+		{
+			name:    "Single value range neither an integer nor letters, passing error up from parseRange",
+			args:    args{input: "node[a1]"},
 			want:    [][]string{},
 			wantErr: true,
 		},
@@ -249,6 +304,91 @@ func Test_parseRange(t *testing.T) {
 			args: args{rangeStr: "[01-02,001-004]"},
 			want: []string{"01", "001", "02", "002", "003", "004"},
 		},
+		{
+			name: "Letters, single value",
+			args: args{rangeStr: "[c]"},
+			want: []string{"c"},
+		},
+		{
+			name: "Letters, range value",
+			args: args{rangeStr: "[a-c]"},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "Letters, multi-letter range value with step",
+			args: args{rangeStr: "[y-ad/2]"},
+			want: []string{"y", "aa", "ac"},
+		},
+		{
+			name: "Letters, upper case range value",
+			args: args{rangeStr: "[A-C]"},
+			want: []string{"A", "B", "C"},
+		},
+		{
+			name: "Letters and digits union",
+			args: args{rangeStr: "[b,1-2,a]"},
+			want: []string{"1", "2", "a", "b"},
+		},
+		{
+			name: "Hexadecimal range value",
+			args: args{rangeStr: "[0x0e-0x11]"},
+			want: []string{"0e", "0f", "10", "11"},
+		},
+		{
+			name: "Hexadecimal range value, upper case and step",
+			args: args{rangeStr: "[0x00-0x3F/16]"},
+			want: []string{"00", "10", "20", "30"},
+		},
+		{
+			name: "Single value keeps its zero padding",
+			args: args{rangeStr: "[01,05-07]"},
+			want: []string{"01", "05", "06", "07"},
+		},
+		{
+			name: "Same value with several zero paddings",
+			args: args{rangeStr: "[1,01,001]"},
+			want: []string{"1", "01", "001"},
+		},
+		{
+			name: "Hexadecimal single value keeps its width",
+			args: args{rangeStr: "[0x0a1f]"},
+			want: []string{"0a1f"},
+		},
+		{
+			name: "Hexadecimal range value with padding beyond its width",
+			args: args{rangeStr: "[0x0fe-0x101]"},
+			want: []string{"0fe", "0ff", "100", "101"},
+		},
+		{
+			name:    "Hexadecimal range value of mixed case",
+			args:    args{rangeStr: "[0x0a-0x1F]"},
+			want:    []string{},
+			wantErr: true,
+		},
+		{
+			name:    "Hexadecimal range value without digits",
+			args:    args{rangeStr: "[0x-0x1f]"},
+			want:    []string{},
+			wantErr: true,
+		},
+		{
+			name:    "Letters of mixed case",
+			args:    args{rangeStr: "[aB]"},
+			want:    []string{},
+			wantErr: true,
+		},
+		{
+			name:    "Range of lower and upper case letters",
+			args:    args{rangeStr: "[a-C]"},
+			want:    []string{},
+			wantErr: true,
+		},
+		{
+			name:    "Range of letters ending before its start",
+			args:    args{rangeStr: "[az-z]"},
+			want:    []string{},
+			wantErr: true,
+		},
 		{
 			name:    "Missing brackets",
 			args:    args{rangeStr: "1"},
@@ -274,8 +414,8 @@ func Test_parseRange(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name:    "Single value range non-integer",
-			args:    args{rangeStr: "[a]"},
+			name:    "Single value range neither an integer nor letters",
+			args:    args{rangeStr: "[a1]"},
 			want:    []string{},
 			wantErr: true,
 		},
@@ -291,6 +431,18 @@ func Test_parseRange(t *testing.T) {
 			want:    []string{},
 			wantErr: true,
 		},
+		{
+			name:    "Range with more than one range delineator",
+			args:    args{rangeStr: "[1-2-3]"},
+			want:    []string{},
+			wantErr: true,
+		},
+		{
+			name:    "Range with an empty value between range delineators",
+			args:    args{rangeStr: "[1--2]"},
+			want:    []string{},
+			wantErr: true,
+		},
 		{
 			name:    "Range start value is greater than the end value",
 			args:    args{rangeStr: "[2-1]"},
@@ -3,12 +3,27 @@ package nodeset
 import (
 	"cmp"
 	"fmt"
+	"maps"
 	"slices"
 	"strconv"
 	"strings"
 	"unicode"
 )
 
+// shape describes the structure shared by node names that can be folded together,
+// the non-digit components of the name and the format of each digit component.
+type shape struct {
+	literals []string      // literals[i] precedes digit component i, the final element trails the last digit component.
+	formats  []valueFormat // Format of each digit component, holding its notation and zero padding.
+}
+
+// box is the Cartesian product of a set of values per digit component of a shape.
+type box []rangeSet
+
+// MatchGroup describes a group of node names folded together by the first version of
+// Fold.
+//
+// Deprecated: Fold no longer uses MatchGroup, node names are folded through NodeSet.
 type MatchGroup struct {
 	Length            int                      // Length of original string
 	NonDigitPositions map[int]string           // Key is position index with the non-digit component as a value
@@ -16,196 +31,745 @@ type MatchGroup struct {
 	DigitPositions    map[int]map[int]struct{} // Using a map of maps to only include unique digits: map[<pos. index>]map[<unique value>]struct{}
 }
 
+// shapeGroup collects the boxes of all names sharing a shape.
+type shapeGroup struct {
+	shape shape
+	boxes []box
+}
+
+// component is a piece of a node name, either literal text or a set of digit values.
+type component struct {
+	literal string
+	digits  bool
+	format  valueFormat
+	values  rangeSet
+}
+
+// Fold takes a list of node names and folds them into node set patterns, for example
+// node1, node2, node3 -> node[1-3]. Names are folded along every digit component,
+// but only when the result stays exact, expanding the returned patterns always yields
+// the deduplicated input and nothing else. When the Cartesian product of the digit
+// components would include names that aren't in the input, several patterns are
+// returned instead, for example eh1f0, eh2f1 -> eh1f0, eh2f1. Values of different
+// zero padding share a bracket, for example node09, node10, node1 -> node[1,09-10].
 func Fold(inputs []string) []string {
-	splitInput := make([][]string, len(inputs))
-	for inputIndex, input := range inputs {
-		splitInput[inputIndex] = splitOnDigits(input)
-	}
-
-	matchGroups := findMatchGroups(splitInput)
-	output := make([]string, len(matchGroups))
-
-	for groupIndex, group := range matchGroups {
-		folded := ""
-		for i := 0; i < group.Length; i++ {
-			if _, ok := group.NonDigitPositions[i]; ok {
-				folded += group.NonDigitPositions[i]
-			} else if _, ok := group.DigitPositions[i]; ok {
-
-				// Convert map[int]struct{} into a []int for numericRange()
-				digitPositions := make([]int, len(group.DigitPositions[i]))
-				j := 0
-				for digit := range group.DigitPositions[i] {
-					digitPositions[j] = digit
-					j++
-				}
+	return NewNodeSet(inputs...).patterns(Options{})
+}
+
+// FoldWithOptions is like Fold, but additionally folds arithmetic progressions of at
+// least opts.Autostep values into step ranges, for example node1, node3, node5, node7
+// -> node[1-7/2] with an Autostep of 4, with opts.FoldLetters names differing by their
+// last letter into alphabetic ranges, for example ossa, ossb, ossc -> oss[a-c], and
+// with opts.FoldHex runs of hexadecimal digits into hexadecimal ranges, for example
+// bmc-0a1e, bmc-0a1f -> bmc-[0x0a1e-0x0a1f]. With opts.FoldAlternations, patterns only
+// differing by literal text are then factored into alternations, for example
+// node[1-4]-eth, node[1-4]-ib -> node[1-4]-{eth,ib}. Expanding the returned patterns
+// yields the same names as those returned by Fold.
+func FoldWithOptions(inputs []string, opts Options) []string {
+	return NewNodeSet(inputs...).patterns(opts)
+}
+
+// splitShape splits a node name into its shape and a single value box.
+func splitShape(name string) (string, shape, box) {
+	return newShape(nameComponents(name))
+}
+
+// splitHexShape is like splitShape, but splits each run of hexadecimal digits found by
+// hexRuns into a single hexadecimal component, see Options.FoldHex.
+func splitHexShape(name string) (string, shape, box) {
+	var components []component
+	pos := 0
+	for _, run := range hexRuns(name) {
+		components = append(components, nameComponents(name[pos:run[0]])...)
+		digits := name[run[0]:run[1]]
+		n := hexNotation(digits)
+		if v, ok := n.parse(digits); ok {
+			components = append(components, component{digits: true, format: valueFormat{notation: n, padding: digitPadding(digits)}, values: singleRange(v)})
+		} else {
+			// Runs too large to be folded are kept as is.
+			components = append(components, component{literal: digits})
+		}
+		pos = run[1]
+	}
+	return newShape(append(components, nameComponents(name[pos:])...))
+}
+
+// hexRuns returns the start and end of each run of hexadecimal digits of name, as
+// described by Options.FoldHex.
+func hexRuns(name string) [][2]int {
+	var runs [][2]int
+	for i := 0; i < len(name); {
+		if !isAlnum(name[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(name) && isAlnum(name[j]) {
+			j++
+		}
+		run := name[i:j]
+		lower, upper := strings.ContainsAny(run, "abcdef"), strings.ContainsAny(run, "ABCDEF")
+		if i > 0 && isDigit(run[0]) && lower != upper && strings.Trim(run, "0123456789abcdefABCDEF") == "" {
+			runs = append(runs, [2]int{i, j})
+		}
+		i = j
+	}
+	return runs
+}
+
+// hasHexRuns reports whether the names of s hold runs of hexadecimal digits. Digit
+// components can't start or end a run, so every name of s holds the same runs.
+func (s shape) hasHexRuns() bool {
+	var sb strings.Builder
+	for i, literal := range s.literals {
+		if i > 0 {
+			sb.WriteByte('0')
+		}
+		sb.WriteString(literal)
+	}
+	return hexRuns(sb.String()) != nil
+}
 
-				ranges, bracket := numericRange(digitPositions, group.DigitPadding[i])
-				folded += formatRange(ranges, bracket)
+// nameComponents splits s into literal and digit components.
+func nameComponents(s string) []component {
+	var components []component
+	for _, element := range splitOnDigits(s) {
+		val, err := strconv.ParseUint(element, 10, 64)
+		if err != nil {
+			// Non-digit elements, and digits too large to be folded, are kept as is.
+			components = append(components, component{literal: element})
+			continue
+		}
+		components = append(components, component{digits: true, format: valueFormat{padding: digitPadding(element)}, values: singleRange(val)})
+	}
+	return components
+}
+
+// digitPadding returns the zero padding of a string of digits, 0 if it has no leading zero.
+func digitPadding(digits string) int {
+	if len(digits) > 1 && digits[0] == '0' {
+		return len(digits)
+	}
+	return 0
+}
+
+// newShape builds the shape and box of a sequence of components, merging adjacent literals.
+// The returned key is unique per shape. Length of padded digits is part of the shape to
+// make comparing padded digits easier, digits of different lengths are only folded
+// together when formatting, see foldBoxes.
+func newShape(components []component) (string, shape, box) {
+	var s shape
+	var b box
+	var key strings.Builder
+	literal := ""
+
+	for _, c := range components {
+		if !c.digits {
+			literal += c.literal
+			continue
+		}
+		s.literals = append(s.literals, literal)
+		s.formats = append(s.formats, c.format)
+		b = append(b, c.values)
+		fmt.Fprintf(&key, "%s\x00%d\x00%d\x00", literal, c.format.notation, c.format.padding)
+		literal = ""
+	}
+	s.literals = append(s.literals, literal)
+	key.WriteString(literal)
+
+	return key.String(), s, b
+}
+
+// format returns the node set pattern of the box b of shape s, see rangeSet.format for autostep.
+func (s shape) format(b box, autostep int) string {
+	var sb strings.Builder
+	for i, values := range b {
+		sb.WriteString(s.literals[i])
+		sb.WriteString(formatRange(values.format(s.formats[i], autostep)))
+	}
+	sb.WriteString(s.literals[len(s.literals)-1])
+	return sb.String()
+}
+
+// decimal reports whether every digit component of s is written in decimal, which is
+// always the case for shapes split from names.
+func (s shape) decimal() bool {
+	return !slices.ContainsFunc(s.formats, func(f valueFormat) bool { return f.notation != Decimal })
+}
+
+// parse returns the value of each digit component of name, false if name doesn't have
+// shape s. Shapes are built so that a name has at most one way to be split into their
+// literals and digit components, see splitNotations.
+func (s shape) parse(name string) ([]uint64, bool) {
+	values := make([]uint64, len(s.formats))
+	var match func(i int, rest string) bool
+	match = func(i int, rest string) bool {
+		rest, ok := strings.CutPrefix(rest, s.literals[i])
+		if !ok {
+			return false
+		}
+		if i == len(s.formats) {
+			return rest == ""
+		}
+		f := s.formats[i]
+		alphabet := f.notation.alphabet()
+		for end := 1; end <= len(rest) && strings.IndexByte(alphabet, rest[end-1]) >= 0; end++ {
+			v, ok := f.notation.parse(rest[:end])
+			if ok && f.format(v) == rest[:end] && match(i+1, rest[end:]) {
+				values[i] = v
+				return true
 			}
 		}
-		output[groupIndex] = folded
+		return false
+	}
+	return values, match(0, name)
+}
+
+// mayShare reports whether shapes a and b may have names in common, only looking at the
+// characters their literals and digit components are written with, not at the values
+// of their components. It walks both shapes a character at a time, like matching a
+// name against both, where a digit component stands for one or more characters of its
+// notation. Different shapes only differing by zero padding never share names, as
+// their names are split the same way and their values are written with different
+// lengths.
+func mayShare(a, b shape) bool {
+	if a.familyKey() == b.familyKey() {
+		return false
+	}
+	ta, tb := a.tokens(), b.tokens()
+	// state is the position in both token lists, and whether the current token of
+	// either is a digit component already holding a character.
+	type state struct {
+		i, j     int
+		inA, inB bool
+	}
+	seen := make(map[state]bool)
+	var walk func(s state) bool
+	walk = func(s state) bool {
+		if seen[s] {
+			return false
+		}
+		seen[s] = true
+		if s.i == len(ta) && s.j == len(tb) {
+			return true
+		}
+		if s.inA && walk(state{i: s.i + 1, j: s.j, inB: s.inB}) || s.inB && walk(state{i: s.i, j: s.j + 1, inA: s.inA}) {
+			return true
+		}
+		if s.i == len(ta) || s.j == len(tb) || !sharesByte(ta[s.i].chars, tb[s.j].chars) {
+			return false
+		}
+		next := state{i: s.i, j: s.j, inA: ta[s.i].component, inB: tb[s.j].component}
+		if !next.inA {
+			next.i++
+		}
+		if !next.inB {
+			next.j++
+		}
+		return walk(next)
+	}
+	return walk(state{})
+}
+
+// aligned reports whether the names of s that t may hold are split the same way by both
+// shapes, their literals being the same, so that they share the names whose digit
+// components are written the same in both, see sharedValues. Literals between digit
+// components can't be empty or hold characters of a digit component, which would allow
+// a name to be split differently.
+func (s shape) aligned(t shape) bool {
+	if !slices.Equal(s.literals, t.literals) || len(s.formats) != len(t.formats) {
+		return false
+	}
+	var alphabets strings.Builder
+	for i := range s.formats {
+		alphabets.WriteString(s.formats[i].notation.alphabet())
+		alphabets.WriteString(t.formats[i].notation.alphabet())
+	}
+	for i := 1; i < len(s.formats); i++ {
+		if s.literals[i] == "" || strings.ContainsAny(s.literals[i], alphabets.String()) {
+			return false
+		}
+	}
+	return true
+}
+
+// shapeToken is either a single character of the literals of a shape, or one of its
+// digit components, standing for the characters its values are written with.
+type shapeToken struct {
+	chars     string
+	component bool
+}
+
+// tokens returns the tokens of s in order.
+func (s shape) tokens() []shapeToken {
+	var tokens []shapeToken
+	for i, literal := range s.literals {
+		for j := 0; j < len(literal); j++ {
+			tokens = append(tokens, shapeToken{chars: literal[j : j+1]})
+		}
+		if i < len(s.formats) {
+			tokens = append(tokens, shapeToken{chars: s.formats[i].notation.alphabet(), component: true})
+		}
+	}
+	return tokens
+}
+
+// sharesByte reports whether a and b hold a common byte.
+func sharesByte(a, b string) bool {
+	for i := 0; i < len(a); i++ {
+		if strings.IndexByte(b, a[i]) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// foldLetters folds patterns ending with a letter that only differ by that letter into
+// an alphabetic range, like ossa, ossb, ossc -> oss[a-c], see rangeSet.format for
+// autostep. Patterns sharing everything but their last letter cover the same names
+// apart from that letter, so the folded patterns stay exact.
+func foldLetters(patterns []string, autostep int) []string {
+	type stem struct {
+		prefix   string
+		notation Notation
+	}
+	var stems []stem
+	letters := make(map[stem][]interval)
+	var output []string
+	for _, p := range patterns {
+		last := len(p) - 1
+		if last < 0 || !isLetter(p[last]) {
+			output = append(output, p)
+			continue
+		}
+		v, notation, _, _ := parseValue(p[last:])
+		st := stem{prefix: p[:last], notation: notation}
+		if _, ok := letters[st]; !ok {
+			stems = append(stems, st)
+		}
+		letters[st] = append(letters[st], interval{lo: v, hi: v})
+	}
+	for _, st := range stems {
+		values, bracket := rangeSetOf(letters[st]).format(valueFormat{notation: st.notation}, autostep)
+		output = append(output, st.prefix+formatRange(values, bracket))
 	}
-	slices.SortFunc(output, func(x, y string) int {
-		return -(cmp.Compare(x, y))
-	})
 	return output
 }
 
-// splitOnDigits splits an input string on any digits, where contigious charecters and digits are left together.
-// "ab1000c" -> []string{"ab", "1000", "c"}
-func splitOnDigits(s string) []string {
-	var parts []string
-	startChar := 0
-	startDigit := 0
-	foundChar := false
-	foundDigit := false
+// foldAlternations factors patterns only differing by the literal text at the same
+// position into an alternation, like node[1-4]-eth, node[1-4]-ib -> node[1-4]-{eth,ib},
+// repeatedly factoring the largest set of such patterns. Patterns sharing everything
+// but that text cover the same names apart from it, so the factored patterns stay exact.
+func foldAlternations(patterns []string) []string {
+	tokens := make([][]string, len(patterns))
+	for i, p := range patterns {
+		tokens[i] = patternTokens(p)
+	}
 
-	for i, char := range s {
-		if unicode.IsDigit(char) {
-			if !foundDigit {
-				startDigit = i
-				foundDigit = true
+	type group struct {
+		pos     int   // Position of the differing literal token.
+		members []int // Index of the tokens of each pattern of the group.
+	}
+	for {
+		var groups []*group
+		index := make(map[string]*group)
+		for i, t := range tokens {
+			for pos, token := range t {
+				if token[0] == '[' || token[0] == '{' {
+					continue
+				}
+				key := fmt.Sprintf("%d\x00%s\x00\x00%s", pos, strings.Join(t[:pos], "\x00"), strings.Join(t[pos+1:], "\x00"))
+				g, ok := index[key]
+				if !ok {
+					g = &group{pos: pos}
+					index[key] = g
+					groups = append(groups, g)
+				}
+				g.members = append(g.members, i)
 			}
-			if foundChar {
-				parts = append(parts, s[startChar:i])
-				foundChar = false
+		}
+
+		var best *group
+		var factored []string
+		for _, g := range groups {
+			if len(g.members) < 2 || best != nil && len(g.members) <= len(best.members) {
+				continue
 			}
-		} else {
-			if !foundChar {
-				startChar = i
-				foundChar = true
+			var texts []string
+			for _, i := range g.members {
+				texts = append(texts, tokens[i][g.pos])
 			}
-			if foundDigit {
-				parts = append(parts, s[startDigit:i])
-				foundDigit = false
+			prefix, alternatives, suffix := alternation(texts)
+			// An alternation of whole names, like {login,node5}, isn't worth factoring.
+			if prefix == "" && suffix == "" && len(tokens[g.members[0]]) == 1 {
+				continue
 			}
+			best = g
+			factored = slices.DeleteFunc([]string{prefix, alternatives, suffix}, func(s string) bool { return s == "" })
+		}
+		if best == nil {
+			break
+		}
+
+		t := tokens[best.members[0]]
+		tokens[best.members[0]] = slices.Concat(t[:best.pos], factored, t[best.pos+1:])
+		for _, i := range slices.Backward(best.members[1:]) {
+			tokens = slices.Delete(tokens, i, i+1)
 		}
 	}
-	//Add any trailing digits or charecters
-	if foundDigit {
-		parts = append(parts, s[startDigit:])
-	} else if foundChar {
-		parts = append(parts, s[startChar:])
+
+	output := make([]string, len(tokens))
+	for i, t := range tokens {
+		output[i] = strings.Join(t, "")
 	}
-	return parts
+	return output
 }
 
-func numericRange(input []int, padding int) ([]string, bool) {
-	if len(input) == 0 {
-		return []string{}, false
+// alternation returns the longest common prefix and suffix of texts, and an alternation
+// of the rest of each text in lexicographic order, like -, {eth,ib} and an empty suffix
+// for -ib and -eth.
+func alternation(texts []string) (string, string, string) {
+	prefix := texts[0]
+	for _, t := range texts[1:] {
+		n := 0
+		for n < len(prefix) && n < len(t) && prefix[n] == t[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	suffix := texts[0][len(prefix):]
+	for _, t := range texts[1:] {
+		t = t[len(prefix):]
+		n := 0
+		for n < len(suffix) && n < len(t) && suffix[len(suffix)-1-n] == t[len(t)-1-n] {
+			n++
+		}
+		suffix = suffix[len(suffix)-n:]
 	}
 
-	slices.Sort(input)
+	var rest []string
+	for _, t := range texts {
+		rest = append(rest, t[len(prefix):len(t)-len(suffix)])
+	}
+	slices.Sort(rest)
+	return prefix, "{" + strings.Join(rest, ",") + "}", suffix
+}
 
-	var ranges []string
-	var bracket bool
+// patternTokens splits a folded pattern into its literal texts, brackets and alternations.
+func patternTokens(pattern string) []string {
+	var tokens []string
+	for pos := 0; pos < len(pattern); {
+		end := pos + 1
+		switch pattern[pos] {
+		case '[':
+			end = pos + strings.IndexByte(pattern[pos:], ']') + 1
+		case '{':
+			for depth := 1; depth > 0; end++ {
+				switch pattern[end] {
+				case '{':
+					depth++
+				case '}':
+					depth--
+				}
+			}
+		default:
+			for end < len(pattern) && pattern[end] != '[' && pattern[end] != '{' {
+				end++
+			}
+		}
+		tokens = append(tokens, pattern[pos:end])
+		pos = end
+	}
+	return tokens
+}
 
-	start := input[0]
-	end := input[0]
+// mergeBoxes folds boxes of the same shape together. Two boxes are merged along a digit
+// component when all of their other components hold the same values, which keeps the
+// merged box exactly equal to the union of the two. Merging is repeated over every
+// component, starting from the last, until no further boxes can be merged. The values
+// of the boxes merged along a component are collected and built into a single set, so
+// folding many names of a shape doesn't rebuild the set once per name.
+func mergeBoxes(boxes []box) []box {
+	if len(boxes) < 2 {
+		return boxes
+	}
 
-	for i := 1; i < len(input); i++ {
-		if input[i] == end+1 {
-			end = input[i]
-		} else {
-			if start == end {
-				ranges = append(ranges, fmt.Sprintf("%0*d", padding, start))
-			} else {
-				ranges = append(ranges, fmt.Sprintf("%0*d-%0*d", padding, start, padding, end))
-				bracket = true
+	for merged := true; merged; {
+		merged = false
+		for dim := len(boxes[0]) - 1; dim >= 0; dim-- {
+			var result []box
+			var values [][]interval
+			index := make(map[string]int)
+			for _, b := range boxes {
+				key := b.keyWithout(dim)
+				if i, ok := index[key]; ok {
+					values[i] = append(values[i], b[dim]...)
+					merged = true
+				} else {
+					index[key] = len(result)
+					result = append(result, b)
+					values = append(values, slices.Clone(b[dim]))
+				}
+			}
+			for i, b := range result {
+				if len(values[i]) > len(b[dim]) {
+					result[i] = b.with(dim, rangeSetOf(values[i]))
+				}
 			}
-			start = input[i]
-			end = input[i]
+			boxes = result
 		}
 	}
+	return boxes
+}
 
-	if start == end {
-		ranges = append(ranges, fmt.Sprintf("%0*d", padding, start))
-	} else {
-		ranges = append(ranges, fmt.Sprintf("%0*d-%0*d", padding, start, padding, end))
-		bracket = true
+// keyWithout returns a key identifying the values of every component of b except dim.
+func (b box) keyWithout(dim int) string {
+	var sb strings.Builder
+	for i, values := range b {
+		if i != dim {
+			sb.WriteString(values.key())
+		}
+		sb.WriteByte(';')
 	}
+	return sb.String()
+}
 
-	if len(ranges) > 1 {
-		bracket = true
-	}
+// with returns a copy of b with the values of component dim replaced.
+func (b box) with(dim int, values rangeSet) box {
+	c := slices.Clone(b)
+	c[dim] = values
+	return c
+}
 
-	return ranges, bracket
+// widthSet holds the values of a digit component written with a single zero padding.
+type widthSet struct {
+	format valueFormat
+	values rangeSet
 }
 
-func formatRange(ranges []string, bracket bool) string {
-	if bracket {
-		return fmt.Sprintf("[%s]", strings.Join(ranges, ","))
+// foldedBox is the Cartesian product of a set of values per digit component of shapes
+// only differing by the zero padding of their digit components, like node[01-09] and
+// node10. Each component holds a widthSet per padding, ordered by padding.
+type foldedBox struct {
+	literals   []string
+	components [][]widthSet
+}
+
+// folded returns the box b of shape s as a folded box.
+func (s shape) folded(b box) foldedBox {
+	components := make([][]widthSet, len(b))
+	for i, values := range b {
+		components[i] = []widthSet{{format: s.formats[i], values: values}}
 	}
-	return fmt.Sprintf("%s", strings.Join(ranges, ","))
+	return foldedBox{literals: s.literals, components: components}
+}
+
+// familyKey returns a key shared by the shapes only differing by the zero padding of
+// their digit components.
+func (s shape) familyKey() string {
+	var key strings.Builder
+	for i, f := range s.formats {
+		fmt.Fprintf(&key, "%s\x00%d\x00", s.literals[i], f.notation)
+	}
+	key.WriteString(s.literals[len(s.literals)-1])
+	return key.String()
 }
 
-func findMatchGroups(input [][]string) []MatchGroup {
-	matchGroups := []MatchGroup{}
+// foldBoxes returns the boxes of groups as folded boxes. Boxes of shapes only differing
+// by zero padding are merged along a digit component when all of their other components
+// hold the same values, like mergeBoxes does within a shape, so node[01-09] and node10
+// are folded into node[01-10].
+func foldBoxes(groups map[string]*shapeGroup) []foldedBox {
+	type family struct {
+		literals []string
+		boxes    [][][]widthSet
+	}
+	var keys []string
+	families := make(map[string]*family)
+	for _, key := range slices.Sorted(maps.Keys(groups)) {
+		group := groups[key]
+		familyKey := group.shape.familyKey()
+		f, ok := families[familyKey]
+		if !ok {
+			f = &family{literals: group.shape.literals}
+			families[familyKey] = f
+			keys = append(keys, familyKey)
+		}
+		for _, b := range group.boxes {
+			f.boxes = append(f.boxes, group.shape.folded(b).components)
+		}
+	}
 
-	positionMap := make(map[string]MatchGroup)
+	var result []foldedBox
+	for _, key := range keys {
+		f := families[key]
+		for _, components := range mergeWidths(f.boxes) {
+			result = append(result, foldedBox{literals: f.literals, components: components})
+		}
+	}
+	return result
+}
 
-	for _, slice := range input {
-		// Generate unique key using non-digit elements + length of digit elements.
-		// Length is included to make comparing padded digits easier, at the expense of
-		// not being able to fold different length digits together.
-		key := ""
-		padding := false
+// mergeWidths merges the components of folded boxes like mergeBoxes merges boxes,
+// uniting the values of each padding of the merged component.
+func mergeWidths(boxes [][][]widthSet) [][][]widthSet {
+	if len(boxes) < 2 {
+		return boxes
+	}
 
-		for _, element := range slice {
-			_, err := strconv.Atoi(element)
-			if err != nil {
-				key += element
-			} else {
-				length := len(element)
-				if length > 1 && element[0] == '0' {
-					key += strconv.Itoa(length)
-					padding = true
+	for merged := true; merged; {
+		merged = false
+		for dim := len(boxes[0]) - 1; dim >= 0; dim-- {
+			var result [][][]widthSet
+			index := make(map[string]int)
+			for _, b := range boxes {
+				key := widthsKeyWithout(b, dim)
+				if i, ok := index[key]; ok {
+					result[i] = slices.Clone(result[i])
+					result[i][dim] = unionWidths(result[i][dim], b[dim])
+					merged = true
+				} else {
+					index[key] = len(result)
+					result = append(result, b)
 				}
 			}
+			boxes = result
 		}
+	}
+	return boxes
+}
 
-		// Check if a group with the same key exists
-		if group, ok := positionMap[key]; ok {
-			// Group exists, append the slice to the existing group
-			positionMap[key] = updatePositions(group, slice, padding)
-		} else {
-			// Group doesn't exist, create a new group
-			group := MatchGroup{
-				Length:            len(slice),
-				DigitPadding:      make(map[int]int),
-				NonDigitPositions: make(map[int]string),
-				DigitPositions:    make(map[int]map[int]struct{}),
+// widthsKeyWithout returns a key identifying the values of every padding of every
+// component of b except dim.
+func widthsKeyWithout(b [][]widthSet, dim int) string {
+	var sb strings.Builder
+	for i, widths := range b {
+		if i != dim {
+			for _, w := range widths {
+				fmt.Fprintf(&sb, "%d:%s|", w.format.padding, w.values.key())
 			}
-			positionMap[key] = updatePositions(group, slice, padding)
 		}
+		sb.WriteByte(';')
 	}
+	return sb.String()
+}
 
-	// Convert map values to slice
-	for _, group := range positionMap {
-		matchGroups = append(matchGroups, group)
+// unionWidths returns the values of both a and b per padding, ordered by padding.
+func unionWidths(a, b []widthSet) []widthSet {
+	result := slices.Clone(a)
+	for _, w := range b {
+		i, found := slices.BinarySearchFunc(result, w.format.padding, func(x widthSet, padding int) int {
+			return cmp.Compare(x.format.padding, padding)
+		})
+		if found {
+			result[i].values = result[i].values.union(w.values)
+		} else {
+			result = slices.Insert(result, i, w)
+		}
 	}
+	return result
+}
 
-	return matchGroups
+// format returns the node set pattern of fb, see rangeSet.format for autostep.
+func (fb foldedBox) format(autostep int) string {
+	var sb strings.Builder
+	for i, widths := range fb.components {
+		sb.WriteString(fb.literals[i])
+		sb.WriteString(formatWidths(widths, autostep))
+	}
+	sb.WriteString(fb.literals[len(fb.literals)-1])
+	return sb.String()
 }
 
-func updatePositions(group MatchGroup, slice []string, padding bool) MatchGroup {
-	for i, element := range slice {
-		if val, err := strconv.Atoi(element); err == nil {
-			if _, ok := group.DigitPositions[i]; !ok {
-				group.DigitPositions[i] = make(map[int]struct{})
+// formatWidths returns the values of a digit component written with one or more zero
+// paddings, as a single value or a bracket, see rangeSet.format for autostep. Padded
+// values continuing into values too long to be padded are written as a single range,
+// like 01-09 and 10-12 -> 01-12, which expands to the same names.
+func formatWidths(widths []widthSet, autostep int) string {
+	if len(widths) == 1 {
+		return formatRange(widths[0].values.format(widths[0].format, autostep))
+	}
+
+	widths = slices.Clone(widths)
+	if widths[0].format.padding == 0 {
+		for i := 1; i < len(widths); i++ {
+			w := &widths[i]
+			bound, ok := pow(w.format.notation.base(), w.format.padding-1)
+			if !ok || w.values.last() != bound-1 {
+				continue
 			}
-			if _, ok := group.DigitPositions[i][val]; !ok {
-				group.DigitPositions[i][val] = struct{}{}
-				if padding {
-					group.DigitPadding[i] = len(element)
+			for _, iv := range widths[0].values {
+				if iv.lo == bound {
+					w.values = w.values.union(rangeSet{iv})
+					widths[0].values = widths[0].values.subtract(rangeSet{iv})
+					break
 				}
 			}
+		}
+		if len(widths[0].values) == 0 {
+			widths = widths[1:]
+		}
+	}
+	var elements []string
+	for _, w := range byFirstValue(widths) {
+		values, bracket := w.values.format(w.format, autostep)
+		if !bracket {
+			values[0] = w.format.marker() + values[0]
+		}
+		elements = append(elements, values...)
+	}
+	return formatRange(elements, true)
+}
+
+// byFirstValue returns widths ordered by their first value, then by padding.
+func byFirstValue(widths []widthSet) []widthSet {
+	return slices.SortedStableFunc(slices.Values(widths), func(x, y widthSet) int {
+		return cmp.Compare(x.values[0].lo, y.values[0].lo)
+	})
+}
+
+// splitOnDigits splits an input string on any digits, where contigious charecters and digits are left together.
+// "ab1000c" -> []string{"ab", "1000", "c"}
+func splitOnDigits(s string) []string {
+	var parts []string
+	startChar := 0
+	startDigit := 0
+	foundChar := false
+	foundDigit := false
+
+	for i, char := range s {
+		if unicode.IsDigit(char) {
+			if !foundDigit {
+				startDigit = i
+				foundDigit = true
+			}
+			if foundChar {
+				parts = append(parts, s[startChar:i])
+				foundChar = false
+			}
 		} else {
-			if group.NonDigitPositions[i] == "" {
-				group.NonDigitPositions[i] = element
+			if !foundChar {
+				startChar = i
+				foundChar = true
+			}
+			if foundDigit {
+				parts = append(parts, s[startDigit:i])
+				foundDigit = false
 			}
 		}
 	}
-	return group
+	//Add any trailing digits or charecters
+	if foundDigit {
+		parts = append(parts, s[startDigit:])
+	} else if foundChar {
+		parts = append(parts, s[startChar:])
+	}
+	return parts
+}
+
+func formatRange(ranges []string, bracket bool) string {
+	if bracket {
+		return fmt.Sprintf("[%s]", strings.Join(ranges, ","))
+	}
+	return fmt.Sprintf("%s", strings.Join(ranges, ","))
 }
@@ -1,7 +1,10 @@
 package nodeset
 
 import (
+	"fmt"
 	"reflect"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -29,7 +32,7 @@ func TestFold(t *testing.T) {
 		{
 			name:     "Duplicates",
 			input:    []string{"g1", "g1", "g01"},
-			expected: []string{"g1", "g01"},
+			expected: []string{"g[1,01]"},
 		},
 		{
 			name:     "Range with gap",
@@ -46,15 +49,45 @@ func TestFold(t *testing.T) {
 			input:    []string{"eh1f0", "eh1f1", "eh2f0", "eh2f1"},
 			expected: []string{"eh[1-2]f[0-1]"},
 		},
+		{
+			name:     "Multiple ranges, diagonal isn't folded",
+			input:    []string{"eh1f0", "eh2f1"},
+			expected: []string{"eh2f1", "eh1f0"},
+		},
+		{
+			name:     "Multiple ranges, partial product",
+			input:    []string{"eh1f0", "eh1f1", "eh2f0"},
+			expected: []string{"eh2f0", "eh1f[0-1]"},
+		},
+		{
+			name:     "Multiple ranges, merged along first digits",
+			input:    []string{"r1n1", "r1n2", "r2n1", "r2n2", "r3n5"},
+			expected: []string{"r[1-2]n[1-2]", "r3n5"},
+		},
 		{
 			name:     "Digits increasing in length",
 			input:    []string{"k9", "k10"},
 			expected: []string{"k[9-10]"},
 		},
 		{
-			name:     "Mixed padding, folding not supported",
+			name:     "Mixed padding",
 			input:    []string{"k2", "k03", "k004"},
-			expected: []string{"k2", "k03", "k004"},
+			expected: []string{"k[2,03,004]"},
+		},
+		{
+			name:     "Padded values continued by longer values",
+			input:    []string{"node08", "node09", "node10", "node11"},
+			expected: []string{"node[08-11]"},
+		},
+		{
+			name:     "Mixed padding along one of several ranges",
+			input:    []string{"r1n09", "r1n10", "r2n09", "r2n10"},
+			expected: []string{"r[1-2]n[09-10]"},
+		},
+		{
+			name:     "Same value with several paddings",
+			input:    []string{"n1", "n01", "n001", "n2"},
+			expected: []string{"n[1-2,01,001]"},
 		},
 	}
 
@@ -70,6 +103,293 @@ func TestFold(t *testing.T) {
 	}
 }
 
+func TestFoldRoundTrip(t *testing.T) {
+	testCases := [][]string{
+		{"eh1f0", "eh2f1"},
+		{"a1b1", "a1b2", "a2b1", "a3b3", "a3b4", "a4b3", "a4b4"},
+		{"r1n1c1", "r1n1c2", "r1n2c1", "r2n1c1", "r2n1c2", "r2n2c1", "r9n9c9"},
+		{"x01y1", "x02y1", "x01y2", "x1y1", "x2y1"},
+		{"n3", "n01", "n09", "n10", "n11", "n100", "n099"},
+	}
+
+	for _, input := range testCases {
+		t.Run(strings.Join(input, ","), func(t *testing.T) {
+			var expanded []string
+			for _, pattern := range Fold(input) {
+				err := Expand(pattern, func(s string) error {
+					expanded = append(expanded, s)
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("Expand(%s) error = %v", pattern, err)
+				}
+			}
+			want := slices.Clone(input)
+			slices.Sort(want)
+			slices.Sort(expanded)
+			if !reflect.DeepEqual(expanded, want) {
+				t.Errorf("Expected %v, but got %v", want, expanded)
+			}
+		})
+	}
+}
+
+func TestFoldWithOptionsAutostep(t *testing.T) {
+	odd := func(prefix string, lo, hi int) []string {
+		var names []string
+		for i := lo; i <= hi; i += 2 {
+			names = append(names, fmt.Sprintf("%s%d", prefix, i))
+		}
+		return names
+	}
+	testCases := []struct {
+		name     string
+		input    []string
+		autostep int
+		expected []string
+	}{
+		{
+			name:     "Odd nodes",
+			input:    odd("node", 1, 99),
+			autostep: 3,
+			expected: []string{"node[1-99/2]"},
+		},
+		{
+			name:     "Disabled",
+			input:    odd("node", 1, 5),
+			autostep: 0,
+			expected: []string{"node[1,3,5]"},
+		},
+		{
+			name:     "Below threshold",
+			input:    odd("node", 1, 5),
+			autostep: 4,
+			expected: []string{"node[1,3,5]"},
+		},
+		{
+			name:     "Progression after a gap",
+			input:    []string{"n1", "n4", "n6", "n8", "n10"},
+			autostep: 3,
+			expected: []string{"n[1,4-10/2]"},
+		},
+		{
+			name:     "Contiguous ranges kept",
+			input:    []string{"n1", "n2", "n3", "n5", "n7", "n9", "n10"},
+			autostep: 2,
+			expected: []string{"n[1-3,5-7/2,9-10]"},
+		},
+		{
+			name:     "Padding",
+			input:    []string{"n001", "n004", "n007", "n010"},
+			autostep: 4,
+			expected: []string{"n[001-010/3]"},
+		},
+		{
+			name:     "Padded progression followed by longer values",
+			input:    []string{"n01", "n04", "n07", "n10", "n13", "n16"},
+			autostep: 3,
+			expected: []string{"n[01-07/3,10-16/3]"},
+		},
+		{
+			name:     "Multiple ranges",
+			input:    []string{"r1n1", "r1n3", "r1n5", "r3n1", "r3n3", "r3n5"},
+			autostep: 2,
+			expected: []string{"r[1-3/2]n[1-5/2]"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := FoldWithOptions(tc.input, Options{Autostep: tc.autostep})
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected %v, but got %v", tc.expected, result)
+			}
+
+			var expanded []string
+			for _, pattern := range result {
+				err := Expand(pattern, func(s string) error {
+					expanded = append(expanded, s)
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("Expand(%s) error = %v", pattern, err)
+				}
+			}
+			want := slices.Clone(tc.input)
+			slices.Sort(want)
+			slices.Sort(expanded)
+			if !reflect.DeepEqual(expanded, want) {
+				t.Errorf("Expected %v, but got %v", want, expanded)
+			}
+		})
+	}
+}
+
+func TestFoldWithOptionsLetters(t *testing.T) {
+	testCases := []struct {
+		name        string
+		input       []string
+		foldLetters bool
+		expected    []string
+	}{
+		{
+			name:        "Trailing letters",
+			input:       []string{"ossa", "ossb", "ossc", "osse"},
+			foldLetters: true,
+			expected:    []string{"oss[a-c,e]"},
+		},
+		{
+			name:        "Disabled",
+			input:       []string{"ossa", "ossb"},
+			foldLetters: false,
+			expected:    []string{"ossb", "ossa"},
+		},
+		{
+			name:        "Letters after digits",
+			input:       []string{"sw1a", "sw1b", "sw2a", "sw2b", "login"},
+			foldLetters: true,
+			expected:    []string{"sw[1-2][a-b]", "login"},
+		},
+		{
+			name:        "Letter cases kept apart",
+			input:       []string{"nA", "nB", "na"},
+			foldLetters: true,
+			expected:    []string{"na", "n[A-B]"},
+		},
+		{
+			name:        "Only the last letter folded",
+			input:       []string{"rackaz", "rackba"},
+			foldLetters: true,
+			expected:    []string{"rackba", "rackaz"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := FoldWithOptions(tc.input, Options{FoldLetters: tc.foldLetters})
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected %v, but got %v", tc.expected, result)
+			}
+
+			ns, err := Parse(strings.Join(result, ","))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if want := NewNodeSet(tc.input...); !ns.Equal(want) {
+				t.Errorf("Parse() = %v, want %v", ns, want)
+			}
+		})
+	}
+}
+
+func TestFoldWithOptionsHex(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    []string
+		expected []string
+	}{
+		{
+			name:     "Hexadecimal run",
+			input:    []string{"bmc-0a1e", "bmc-0a1f", "bmc-0a20", "bmc-0a22"},
+			expected: []string{"bmc-[0x0a1e-0x0a20,0x0a22]"},
+		},
+		{
+			name:     "Upper case",
+			input:    []string{"port-3E", "port-3F"},
+			expected: []string{"port-[0x3E-0x3F]"},
+		},
+		{
+			name:     "Single name",
+			input:    []string{"bmc-0a1f"},
+			expected: []string{"bmc-0a1f"},
+		},
+		{
+			name:     "Runs between separators",
+			input:    []string{"00:1a:2b", "00:1a:2c", "00:1b:2b", "00:1b:2c"},
+			expected: []string{"00:[0x1a-0x1b]:[0x2b-0x2c]"},
+		},
+		{
+			name:     "Decimal digits and words left alone",
+			input:    []string{"db1", "db2", "node-01", "node-02"},
+			expected: []string{"node-[01-02]", "db[1-2]"},
+		},
+		{
+			name:     "Words made of hexadecimal digits left alone",
+			input:    []string{"node-db1", "node-db2", "gpu-cafe"},
+			expected: []string{"node-db[1-2]", "gpu-cafe"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := FoldWithOptions(tc.input, Options{FoldHex: true})
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected %v, but got %v", tc.expected, result)
+			}
+
+			ns, err := Parse(strings.Join(result, ","))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if want := NewNodeSet(tc.input...); !ns.Equal(want) {
+				t.Errorf("Parse() = %v, want %v", ns, want)
+			}
+		})
+	}
+}
+
+func TestFoldWithOptionsAlternations(t *testing.T) {
+	var interfaces []string
+	for _, suffix := range []string{"ib", "eth", "bmc"} {
+		for i := 1; i <= 4; i++ {
+			interfaces = append(interfaces, fmt.Sprintf("node%d-%s", i, suffix))
+		}
+	}
+	testCases := []struct {
+		name     string
+		input    []string
+		expected []string
+	}{
+		{
+			name:     "Interface suffixes",
+			input:    interfaces,
+			expected: []string{"node[1-4]-{bmc,eth,ib}"},
+		},
+		{
+			name:     "Shared prefix and suffix",
+			input:    []string{"rack1-sw-a.mgmt", "rack1-sw-core.mgmt"},
+			expected: []string{"rack1-sw-{a,core}.mgmt"},
+		},
+		{
+			name:     "Different ranges kept apart",
+			input:    []string{"node1-ib", "node2-ib", "node1-eth"},
+			expected: []string{"node[1-2]-ib", "node1-eth"},
+		},
+		{
+			name:     "Unrelated names",
+			input:    []string{"login", "node5"},
+			expected: []string{"node5", "login"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := FoldWithOptions(tc.input, Options{FoldAlternations: true})
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected %v, but got %v", tc.expected, result)
+			}
+
+			ns, err := Parse(strings.Join(result, ","))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if want := NewNodeSet(tc.input...); !ns.Equal(want) {
+				t.Errorf("Parse() = %v, want %v", ns, want)
+			}
+		})
+	}
+}
+
 func TestSplitOnDigits(t *testing.T) {
 	testCases := []struct {
 		name     string
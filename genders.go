@@ -0,0 +1,279 @@
+package nodeset
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// DefaultGendersFile is the genders file read by the nodeset command.
+const DefaultGendersFile = "/etc/genders"
+
+// Genders holds the nodes of a genders file and the attributes of each node. Each line
+// of a genders file lists nodes followed by their comma separated attributes, which
+// may have a value, for example:
+//
+//	# Compute nodes
+//	node[1-64]   compute,gpu=a100,rack=3
+//	login[1-2]   login,mgmt=%n-bmc
+//
+// Nodes may be listed on several lines, collecting the attributes of every line. An
+// occurrence of %n in a value is replaced by the name of the node.
+type Genders struct {
+	attrs map[string]map[string]string // Attribute values per node name.
+}
+
+// LoadGenders reads the genders file at path.
+func LoadGenders(path string) (*Genders, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	g, err := ParseGenders(f)
+	if err != nil {
+		return nil, fmt.Errorf("genders file %s, %w", path, err)
+	}
+	return g, nil
+}
+
+// ParseGenders reads a genders file from r.
+func ParseGenders(r io.Reader) (*Genders, error) {
+	g := &Genders{attrs: make(map[string]map[string]string)}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("line %d, contains whitespace within the attributes %s", line, strings.Join(fields[1:], " "))
+		}
+		if err := g.addLine(fields); err != nil {
+			return nil, fmt.Errorf("line %d, %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// addLine adds the nodes of a genders line, and its attributes when present.
+func (g *Genders) addLine(fields []string) error {
+	nodes, err := Parse(fields[0])
+	if err != nil {
+		return err
+	}
+	var attrs []string
+	if len(fields) == 2 {
+		attrs = strings.Split(fields[1], ",")
+	}
+	for node := range nodes.All() {
+		if g.attrs[node] == nil {
+			g.attrs[node] = make(map[string]string)
+		}
+		for _, attr := range attrs {
+			name, value, _ := strings.Cut(attr, "=")
+			if name == "" {
+				return fmt.Errorf("attribute %s, is missing a name", attr)
+			}
+			g.attrs[node][name] = strings.ReplaceAll(value, "%n", node)
+		}
+	}
+	return nil
+}
+
+// Nodes returns every node of g.
+func (g *Genders) Nodes() *NodeSet {
+	return NewNodeSet(slices.Collect(maps.Keys(g.attrs))...)
+}
+
+// Attrs returns the attributes of node along with their values, an empty value for
+// attributes without one. The returned map can be modified by the caller.
+func (g *Genders) Attrs(node string) map[string]string {
+	return maps.Clone(g.attrs[node])
+}
+
+// Value returns the value of attr for node, and whether node has the attribute.
+func (g *Genders) Value(node, attr string) (string, bool) {
+	value, ok := g.attrs[node][attr]
+	return value, ok
+}
+
+// Attributes returns the names of every attribute of g, sorted.
+func (g *Genders) Attributes() []string {
+	names := make(map[string]struct{})
+	for _, attrs := range g.attrs {
+		for name := range attrs {
+			names[name] = struct{}{}
+		}
+	}
+	return slices.Sorted(maps.Keys(names))
+}
+
+// Groups returns a group per attribute of g, holding the folded pattern of the nodes
+// with the attribute, whatever its value. The result can be used as a source of a
+// MapResolver.
+func (g *Genders) Groups() map[string]string {
+	groups := make(map[string]string)
+	for _, name := range g.Attributes() {
+		groups[name] = g.match(name, "", false).String()
+	}
+	return groups
+}
+
+// match returns the nodes with attribute name, and value when hasValue is true.
+func (g *Genders) match(name, value string, hasValue bool) *NodeSet {
+	var nodes []string
+	for node, attrs := range g.attrs {
+		v, ok := attrs[name]
+		if ok && (!hasValue || v == value) {
+			nodes = append(nodes, node)
+		}
+	}
+	return NewNodeSet(nodes...)
+}
+
+// ErrBadQuery is returned when a genders query can't be parsed.
+var ErrBadQuery = errors.New("invalid genders query")
+
+// Query returns the nodes matching a genders query, like gpu=a100&&rack=3||login. A
+// query combines attributes, or attributes with a value, using the operators of
+// nodeattr:
+// Union - compute||login
+// Intersection - compute&&gpu
+// Difference - compute--gpu
+// Complement - ~gpu
+// Intersection binds tighter than union and difference, which are evaluated from left
+// to right, and parentheses group parts of a query.
+func (g *Genders) Query(query string) (*NodeSet, error) {
+	q := &gendersQuery{g: g, tokens: tokenizeQuery(query)}
+	ns, err := q.union()
+	if err == nil && q.pos < len(q.tokens) {
+		err = fmt.Errorf("unexpected %s", q.tokens[q.pos])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query %s, %w: %w", query, err, ErrBadQuery)
+	}
+	return ns, nil
+}
+
+// gendersQuery is a recursive descent parser of a tokenized genders query.
+type gendersQuery struct {
+	g      *Genders
+	tokens []string
+	pos    int
+}
+
+// tokenizeQuery splits a genders query into operators, parentheses and attribute terms.
+func tokenizeQuery(query string) []string {
+	var tokens []string
+	for i := 0; i < len(query); {
+		switch {
+		case query[i] == ' ' || query[i] == '\t':
+			i++
+		case query[i] == '(' || query[i] == ')' || query[i] == '~':
+			tokens = append(tokens, query[i:i+1])
+			i++
+		case isQueryOperator(query[i:]):
+			tokens = append(tokens, query[i:i+2])
+			i += 2
+		default:
+			end := i
+			for end < len(query) && !strings.ContainsRune(" \t()~", rune(query[end])) && !isQueryOperator(query[end:]) {
+				end++
+			}
+			tokens = append(tokens, query[i:end])
+			i = end
+		}
+	}
+	return tokens
+}
+
+// isQueryOperator reports whether s starts with a binary operator of a genders query.
+func isQueryOperator(s string) bool {
+	return strings.HasPrefix(s, "||") || strings.HasPrefix(s, "&&") || strings.HasPrefix(s, "--")
+}
+
+// union parses terms joined by || and --.
+func (q *gendersQuery) union() (*NodeSet, error) {
+	result, err := q.intersection()
+	if err != nil {
+		return nil, err
+	}
+	for q.pos < len(q.tokens) && (q.tokens[q.pos] == "||" || q.tokens[q.pos] == "--") {
+		op := q.tokens[q.pos]
+		q.pos++
+		ns, err := q.intersection()
+		if err != nil {
+			return nil, err
+		}
+		if op == "||" {
+			result = result.Union(ns)
+		} else {
+			result = result.Difference(ns)
+		}
+	}
+	return result, nil
+}
+
+// intersection parses terms joined by &&.
+func (q *gendersQuery) intersection() (*NodeSet, error) {
+	result, err := q.term()
+	if err != nil {
+		return nil, err
+	}
+	for q.pos < len(q.tokens) && q.tokens[q.pos] == "&&" {
+		q.pos++
+		ns, err := q.term()
+		if err != nil {
+			return nil, err
+		}
+		result = result.Intersection(ns)
+	}
+	return result, nil
+}
+
+// term parses an attribute, a complemented term or a parenthesized query.
+func (q *gendersQuery) term() (*NodeSet, error) {
+	if q.pos == len(q.tokens) {
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+	token := q.tokens[q.pos]
+	q.pos++
+	switch token {
+	case "~":
+		ns, err := q.term()
+		if err != nil {
+			return nil, err
+		}
+		return q.g.Nodes().Difference(ns), nil
+	case "(":
+		ns, err := q.union()
+		if err != nil {
+			return nil, err
+		}
+		if q.pos == len(q.tokens) || q.tokens[q.pos] != ")" {
+			return nil, fmt.Errorf("missing right parenthesis")
+		}
+		q.pos++
+		return ns, nil
+	case ")", "||", "&&", "--":
+		return nil, fmt.Errorf("unexpected %s", token)
+	}
+	name, value, hasValue := strings.Cut(token, "=")
+	if name == "" {
+		return nil, fmt.Errorf("attribute %s, is missing a name", token)
+	}
+	return q.g.match(name, value, hasValue), nil
+}
@@ -0,0 +1,110 @@
+package nodeset
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testGenders = `
+# Compute nodes
+node[1-8]   compute,rack=1
+node[5-8]   gpu=a100
+node[9-12]  compute,gpu=h100,rack=2
+login[1-2]  login,bmc=%n-bmc  # Login nodes
+login1      rack=1
+`
+
+func TestParseGenders(t *testing.T) {
+	g, err := ParseGenders(strings.NewReader(testGenders))
+	if err != nil {
+		t.Fatalf("ParseGenders() error = %v", err)
+	}
+	if got, want := g.Nodes().String(), "node[1-12],login[1-2]"; got != want {
+		t.Errorf("Nodes() = %v, want %v", got, want)
+	}
+	if got, want := g.Attributes(), []string{"bmc", "compute", "gpu", "login", "rack"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Attributes() = %v, want %v", got, want)
+	}
+	if got, want := g.Attrs("login1"), map[string]string{"login": "", "bmc": "login1-bmc", "rack": "1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Attrs() = %v, want %v", got, want)
+	}
+	if got, ok := g.Value("node6", "gpu"); !ok || got != "a100" {
+		t.Errorf("Value() = %v, %v, want a100, true", got, ok)
+	}
+	if _, ok := g.Value("node1", "gpu"); ok {
+		t.Errorf("Value() of missing attribute = true, want false")
+	}
+
+	r := &MapResolver{Default: "genders", Map: map[string]map[string]string{"genders": g.Groups()}}
+	got, err := ParseWithOptions("@gpu&@login,@bmc", Options{Resolver: r})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() error = %v", err)
+	}
+	if want := "login[1-2]"; got.String() != want {
+		t.Errorf("ParseWithOptions() = %v, want %v", got, want)
+	}
+}
+
+func TestParseGendersErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "Invalid node pattern", input: "node[1-2 compute\n"},
+		{name: "Whitespace in attributes", input: "node1 compute, gpu\n"},
+		{name: "Attribute without a name", input: "node1 compute,=a100\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseGenders(strings.NewReader(tt.input)); err == nil {
+				t.Errorf("ParseGenders() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestGendersQuery(t *testing.T) {
+	g, err := ParseGenders(strings.NewReader(testGenders))
+	if err != nil {
+		t.Fatalf("ParseGenders() error = %v", err)
+	}
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Attribute", query: "compute", want: "node[1-12]"},
+		{name: "Attribute value", query: "gpu=a100", want: "node[5-8]"},
+		{name: "Intersection before union", query: "gpu=a100&&rack=2||login", want: "login[1-2]"},
+		{name: "Intersection and union", query: "gpu&&rack=2||login", want: "node[9-12],login[1-2]"},
+		{name: "Difference", query: "compute--gpu", want: "node[1-4]"},
+		{name: "Complement", query: "~compute", want: "login[1-2]"},
+		{name: "Parentheses", query: "rack=1&&(gpu||login)", want: "node[5-8],login1"},
+		{name: "Whitespace", query: " compute && ~gpu ", want: "node[1-4]"},
+		{name: "Attribute with dashes", query: "no-such-attr", want: ""},
+		{name: "Missing operand", query: "compute&&", wantErr: true},
+		{name: "Missing parenthesis", query: "(compute||login", wantErr: true},
+		{name: "Unexpected parenthesis", query: "compute)", wantErr: true},
+		{name: "Missing attribute name", query: "=a100", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := g.Query(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrBadQuery) {
+					t.Errorf("Query() error = %v, want ErrBadQuery", err)
+				}
+				return
+			}
+			if got.String() != tt.want {
+				t.Errorf("Query() = %v, want %v", got.String(), tt.want)
+			}
+		})
+	}
+}
@@ -1,5 +1,8 @@
 module github.com/bensallen/nodeset
 
-go 1.21
+go 1.23
 
-require github.com/spf13/pflag v1.0.5
+require (
+	github.com/spf13/pflag v1.0.5
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,118 @@
+package nodeset
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultGroupFiles returns the group files read by the nodeset command, the YAML files
+// of /etc/nodeset/groups.d in lexical order followed by the groups.yaml file of the
+// user's configuration directory, like ~/.config/nodeset/groups.yaml.
+func DefaultGroupFiles() []string {
+	files, _ := filepath.Glob("/etc/nodeset/groups.d/*.yaml")
+	slices.Sort(files)
+	if dir, err := os.UserConfigDir(); err == nil {
+		files = append(files, filepath.Join(dir, "nodeset", "groups.yaml"))
+	}
+	return files
+}
+
+// LoadGroupFiles reads group definitions from YAML files and returns them as a
+// MapResolver. Each file maps source names to groups, and each group name to either a
+// pattern or a list of patterns, for example:
+//
+//	rack:
+//	  r1: node[1-32]
+//	  r2: node[33-64]
+//	role:
+//	  compute: "@rack:r[1-2]!@role:login"
+//	  login:
+//	    - node1
+//	    - node33
+//
+// Patterns use the same syntax as Parse, including references to other groups, which
+// are resolved within the same source unless prefixed by another source. Groups
+// defined in more than one file take the definition of the last file. The default
+// source is the first source of the first file. Files that don't exist are skipped.
+func LoadGroupFiles(paths ...string) (*MapResolver, error) {
+	m := &MapResolver{Map: make(map[string]map[string]string)}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := m.loadYAML(data); err != nil {
+			return nil, fmt.Errorf("group file %s, %w", path, err)
+		}
+	}
+	return m, nil
+}
+
+// loadYAML adds the groups of a YAML group file to m.
+func (m *MapResolver) loadYAML(data []byte) error {
+	var doc yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&doc); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d, expected a mapping of source names to groups", root.Line)
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		source, groups := root.Content[i].Value, root.Content[i+1]
+		if groups.Kind != yaml.MappingNode {
+			return fmt.Errorf("line %d, source %s, expected a mapping of group names to patterns", groups.Line, source)
+		}
+		if m.Default == "" {
+			m.Default = source
+		}
+		if m.Map[source] == nil {
+			m.Map[source] = make(map[string]string)
+		}
+		for j := 0; j+1 < len(groups.Content); j += 2 {
+			name, value := groups.Content[j].Value, groups.Content[j+1]
+			pattern, err := groupPattern(value)
+			if err != nil {
+				return fmt.Errorf("line %d, group %s, %w", value.Line, name, err)
+			}
+			m.Map[source][name] = pattern
+		}
+	}
+	return nil
+}
+
+// groupPattern returns the pattern of a group value, a scalar pattern or a list of
+// patterns joined by commas.
+func groupPattern(value *yaml.Node) (string, error) {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		return value.Value, nil
+	case yaml.SequenceNode:
+		var patterns []string
+		if err := value.Decode(&patterns); err != nil {
+			return "", err
+		}
+		return strings.Join(patterns, ","), nil
+	}
+	return "", fmt.Errorf("expected a pattern or a list of patterns")
+}
@@ -0,0 +1,118 @@
+package nodeset
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadGroupFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	site := writeFile("site.yaml", `
+rack:
+  r1: node[1-4]
+  r2: node[5-8]
+role:
+  compute: "@rack:r[1-2]!@login"
+  login:
+    - node1
+    - node5
+`)
+	user := writeFile("user.yaml", `
+rack:
+  r2: node[5-6]
+`)
+
+	tests := []struct {
+		name    string
+		paths   []string
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "Group in default source",
+			paths:   []string{site},
+			pattern: "@r1",
+			want:    "node[1-4]",
+		},
+		{
+			name:    "Group references across sources",
+			paths:   []string{site},
+			pattern: "@role:compute",
+			want:    "node[2-4,6-8]",
+		},
+		{
+			name:    "Later file overrides a group",
+			paths:   []string{site, user},
+			pattern: "@rack:r2",
+			want:    "node[5-6]",
+		},
+		{
+			name:    "Missing file is skipped",
+			paths:   []string{filepath.Join(dir, "missing.yaml"), site},
+			pattern: "@rack:*",
+			want:    "node[1-8]",
+		},
+		{
+			name:    "Source without groups",
+			paths:   []string{writeFile("bad-source.yaml", "rack: node[1-4]\n")},
+			wantErr: true,
+		},
+		{
+			name:    "Group with a mapping value",
+			paths:   []string{writeFile("bad-group.yaml", "rack:\n  r1:\n    a: b\n")},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid YAML",
+			paths:   []string{writeFile("bad-yaml.yaml", "rack: [\n")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := LoadGroupFiles(tt.paths...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadGroupFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got, err := ParseWithOptions(tt.pattern, Options{Resolver: r})
+			if err != nil {
+				t.Fatalf("ParseWithOptions() error = %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("ParseWithOptions() = %v, want %v", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadGroupFilesCycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "groups.yaml")
+	if err := os.WriteFile(path, []byte("local:\n  a: node1,@b\n  b: \"@a\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadGroupFiles(path)
+	if err != nil {
+		t.Fatalf("LoadGroupFiles() error = %v", err)
+	}
+	if got := r.Sources(); !reflect.DeepEqual(got, []string{"local"}) {
+		t.Errorf("Sources() = %v, want [local]", got)
+	}
+	if _, err := ParseWithOptions("@a", Options{Resolver: r}); !errors.Is(err, ErrGroupCycle) {
+		t.Errorf("ParseWithOptions() error = %v, want ErrGroupCycle", err)
+	}
+}
@@ -0,0 +1,211 @@
+package nodeset
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrUnknownGroup is returned when a group reference can't be resolved.
+	ErrUnknownGroup = errors.New("unknown group")
+	// ErrUnknownSource is returned when a group reference names a source that doesn't exist.
+	ErrUnknownSource = errors.New("unknown group source")
+	// ErrGroupCycle is returned when a group references itself, directly or through other groups.
+	ErrGroupCycle = errors.New("group references itself")
+)
+
+// GroupResolver resolves the group references of a pattern, like @compute or
+// @rack:r12, into node patterns. Patterns returned by a GroupResolver may themselves
+// reference other groups. An empty source name stands for the default source of the
+// resolver.
+type GroupResolver interface {
+	// Resolve returns the node pattern of group in source.
+	Resolve(source, group string) (string, error)
+	// Groups returns the names of the groups of source, sorted.
+	Groups(source string) ([]string, error)
+	// All returns a node pattern of every node of source.
+	All(source string) (string, error)
+	// Sources returns the names of the sources of the resolver, sorted.
+	Sources() []string
+}
+
+// MapResolver is a GroupResolver holding the patterns of each group in memory.
+type MapResolver struct {
+	Default string                       // Source of group references without a source.
+	Map     map[string]map[string]string // Pattern per group name, per source name.
+}
+
+// source returns the groups of source, or of the default source when source is empty.
+func (m *MapResolver) source(source string) (map[string]string, error) {
+	if source == "" {
+		source = m.Default
+	}
+	groups, ok := m.Map[source]
+	if !ok {
+		return nil, fmt.Errorf("source %s, %w", source, ErrUnknownSource)
+	}
+	return groups, nil
+}
+
+// Resolve returns the node pattern of group in source.
+func (m *MapResolver) Resolve(source, group string) (string, error) {
+	groups, err := m.source(source)
+	if err != nil {
+		return "", err
+	}
+	pattern, ok := groups[group]
+	if !ok {
+		if source == "" {
+			source = m.Default
+		}
+		return "", fmt.Errorf("source %s, %w", source, ErrUnknownGroup)
+	}
+	return pattern, nil
+}
+
+// Groups returns the names of the groups of source, sorted.
+func (m *MapResolver) Groups(source string) ([]string, error) {
+	groups, err := m.source(source)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Sorted(maps.Keys(groups)), nil
+}
+
+// All returns the patterns of every group of source joined by commas.
+func (m *MapResolver) All(source string) (string, error) {
+	names, err := m.Groups(source)
+	if err != nil {
+		return "", err
+	}
+	groups, _ := m.source(source)
+	patterns := make([]string, 0, len(names))
+	for _, name := range names {
+		if groups[name] != "" {
+			patterns = append(patterns, groups[name])
+		}
+	}
+	return strings.Join(patterns, ","), nil
+}
+
+// Sources returns the names of the sources of m, sorted.
+func (m *MapResolver) Sources() []string {
+	return slices.Sorted(maps.Keys(m.Map))
+}
+
+// parser parses patterns with operators, resolving group references along the way.
+type parser struct {
+	opts      Options
+	source    string   // Source of the group being resolved, used by its own group references.
+	resolving []string // Groups being resolved, to detect cycles.
+}
+
+// parse returns the NodeSet of a pattern with operators, see Parse.
+func (p *parser) parse(pattern string) (*NodeSet, error) {
+	var result *NodeSet
+	err := splitOperators(pattern, func(op byte, operand string) error {
+		ns, err := p.parseOperand(operand)
+		if err != nil {
+			return err
+		}
+		result = result.apply(op, ns)
+		if p.opts.MaxNodes > 0 && result.Len() > p.opts.MaxNodes {
+			return fmt.Errorf("pattern %s, contains %d nodes, more than the maximum of %d: %w", pattern, result.Len(), p.opts.MaxNodes, ErrTooLarge)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// parseOperand returns the NodeSet of a single operand, either a node pattern or a
+// group reference starting with '@'.
+func (p *parser) parseOperand(operand string) (*NodeSet, error) {
+	if operand[0] == '@' {
+		return p.parseGroups(operand)
+	}
+	if p.opts.MaxNodes > 0 {
+		c, err := Compile(operand)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.opts.check(c); err != nil {
+			return nil, err
+		}
+	}
+	return parseOperand(operand)
+}
+
+// parseGroups returns the NodeSet of a group reference like @compute, @rack:r12 or
+// @rack[1-3]. A group name with brackets references every group named by its
+// expansion, and @* or @source:* references every node of a source.
+func (p *parser) parseGroups(ref string) (*NodeSet, error) {
+	if p.opts.Resolver == nil {
+		return nil, parseError(ErrUnknownGroup, ref, 0, ref, "group %s, no group resolver configured", ref)
+	}
+	source, name := p.source, ref[1:]
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		source, name = name[:i], name[i+1:]
+	}
+	if name == "" {
+		return nil, parseError(ErrUnknownGroup, ref, 0, ref, "group reference %s, is missing a group name", ref)
+	}
+
+	if name == "*" {
+		pattern, err := p.opts.Resolver.All(source)
+		if err != nil {
+			return nil, fmt.Errorf("group %s, %w", ref, err)
+		}
+		return p.parseGroup(source, name, pattern)
+	}
+
+	names, err := Names(name)
+	if err != nil {
+		return nil, relocate(err, ref, len(ref)-len(name))
+	}
+	result := &NodeSet{}
+	for group := range names {
+		pattern, err := p.opts.Resolver.Resolve(source, group)
+		if err != nil {
+			return nil, fmt.Errorf("group %s, %w", ref, err)
+		}
+		ns, err := p.parseGroup(source, group, pattern)
+		if err != nil {
+			return nil, err
+		}
+		result = result.Union(ns)
+	}
+	return result, nil
+}
+
+// parseGroup returns the NodeSet of the pattern of a resolved group.
+func (p *parser) parseGroup(source, group, pattern string) (*NodeSet, error) {
+	key := "@" + group
+	if source != "" {
+		key = "@" + source + ":" + group
+	}
+	if slices.Contains(p.resolving, key) {
+		return nil, fmt.Errorf("group %s, through %s: %w", key, strings.Join(p.resolving, ", "), ErrGroupCycle)
+	}
+	if pattern == "" {
+		return &NodeSet{}, nil
+	}
+
+	outer := p.source
+	p.source = source
+	p.resolving = append(p.resolving, key)
+	defer func() {
+		p.source = outer
+		p.resolving = p.resolving[:len(p.resolving)-1]
+	}()
+	ns, err := p.parse(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("group %s, %w", key, err)
+	}
+	return ns, nil
+}
@@ -0,0 +1,136 @@
+package nodeset
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func testResolver() *MapResolver {
+	return &MapResolver{
+		Default: "local",
+		Map: map[string]map[string]string{
+			"local": {
+				"compute": "node[1-10]",
+				"gpu":     "node[9-12]",
+				"drained": "node[2,4]",
+				"rack1":   "node[1-4]",
+				"rack2":   "node[5-8]",
+				"rack3":   "node[9-12]",
+				"racks":   "@rack[1-3]",
+				"loop":    "node1,@cycle",
+				"cycle":   "@loop",
+				"empty":   "",
+			},
+			"slurm": {
+				"debug": "node[1-2],@batch",
+				"batch": "node[3-4]",
+			},
+		},
+	}
+}
+
+func TestParseGroups(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		want     string
+		wantKind error
+	}{
+		{
+			name:    "Group",
+			pattern: "@compute",
+			want:    "node[1-10]",
+		},
+		{
+			name:    "Operators with groups",
+			pattern: "@compute!@drained&@gpu",
+			want:    "node[9-10]",
+		},
+		{
+			name:    "Bracket group names",
+			pattern: "@rack[1,3]",
+			want:    "node[1-4,9-12]",
+		},
+		{
+			name:    "Group referencing groups",
+			pattern: "@racks!node[2-11]",
+			want:    "node[1,12]",
+		},
+		{
+			name:    "Source",
+			pattern: "@slurm:debug",
+			want:    "node[1-4]",
+		},
+		{
+			name:    "All nodes of a source",
+			pattern: "@slurm:*",
+			want:    "node[1-4]",
+		},
+		{
+			name:    "Empty group",
+			pattern: "node1,@empty",
+			want:    "node1",
+		},
+		{
+			name:     "Unknown group",
+			pattern:  "@missing",
+			wantKind: ErrUnknownGroup,
+		},
+		{
+			name:     "Unknown source",
+			pattern:  "@missing:compute",
+			wantKind: ErrUnknownSource,
+		},
+		{
+			name:     "Cycle",
+			pattern:  "@loop",
+			wantKind: ErrGroupCycle,
+		},
+		{
+			name:     "Missing group name",
+			pattern:  "node1,@slurm:",
+			wantKind: ErrUnknownGroup,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWithOptions(tt.pattern, Options{Resolver: testResolver()})
+			if !errors.Is(err, tt.wantKind) || (tt.wantKind == nil && err != nil) {
+				t.Fatalf("ParseWithOptions() error = %v, want kind %v", err, tt.wantKind)
+			}
+			if err != nil {
+				return
+			}
+			if got.String() != tt.want {
+				t.Errorf("ParseWithOptions() = %v, want %v", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGroupsWithoutResolver(t *testing.T) {
+	_, err := Parse("node1,@compute")
+	var pe *ParseError
+	if !errors.As(err, &pe) || !errors.Is(err, ErrUnknownGroup) || pe.Offset != 6 {
+		t.Errorf("Parse() error = %v, want ErrUnknownGroup at offset 6", err)
+	}
+}
+
+func TestMapResolver(t *testing.T) {
+	r := testResolver()
+	if got, want := r.Sources(), []string{"local", "slurm"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Sources() = %v, want %v", got, want)
+	}
+	groups, err := r.Groups("slurm")
+	if want := []string{"batch", "debug"}; err != nil || !reflect.DeepEqual(groups, want) {
+		t.Errorf("Groups() = %v, %v, want %v", groups, err, want)
+	}
+	all, err := r.All("slurm")
+	if want := "node[3-4],node[1-2],@batch"; err != nil || all != want {
+		t.Errorf("All() = %v, %v, want %v", all, err, want)
+	}
+	if _, err := r.Groups("missing"); !errors.Is(err, ErrUnknownSource) {
+		t.Errorf("Groups() error = %v, want ErrUnknownSource", err)
+	}
+}
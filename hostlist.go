@@ -0,0 +1,339 @@
+package nodeset
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ExpandSlurm takes a Slurm hostlist like 'tux[0-3]-ib,linux[01-02]' and calls iter
+// per host name, producing the same names in the same order as 'scontrol show
+// hostnames'. Unlike Expand, names are produced in the order they appear in the
+// hostlist, duplicates are kept, step ranges and operators aren't supported, and each
+// value of a bracket is zero padded to the length of its first value, so [1,01,001]
+// expands to three names and [001-10] to 001 through 010. Hosts may be separated by
+// commas or whitespace.
+func ExpandSlurm(hostlist string, iter func(s string) error) error {
+	return ExpandSlurmWithOptions(hostlist, Options{}, iter)
+}
+
+// splitHostlist validates a Slurm hostlist and splits it on the commas and whitespace
+// outside of brackets, returning a literal segment per host. Empty hosts are skipped.
+func splitHostlist(hostlist string) ([]segment, error) {
+	var hosts []segment
+	start := 0
+	bracket := -1
+	for i := 0; i <= len(hostlist); i++ {
+		if i < len(hostlist) {
+			switch hostlist[i] {
+			case '[':
+				if bracket >= 0 {
+					return nil, parseError(ErrNestedBracket, hostlist, i, hostlist[bracket:i+1], "contains a nested left bracket")
+				}
+				bracket = i
+				continue
+			case ']':
+				if bracket < 0 {
+					return nil, parseError(ErrUnbalancedBracket, hostlist, i, "]", "contains a right bracket without a left bracket")
+				}
+				bracket = -1
+				continue
+			case ',', ' ', '\t', '\n':
+				if bracket >= 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		} else if bracket >= 0 {
+			return nil, parseError(ErrUnbalancedBracket, hostlist, bracket, hostlist[bracket:], "contains a left bracket without a right bracket")
+		}
+		if i > start {
+			hosts = append(hosts, segment{literal: hostlist[start:i], offset: start})
+		}
+		start = i + 1
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("empty hostlist")
+	}
+	return hosts, nil
+}
+
+// ExpandSlurmWithOptions is like ExpandSlurm, but first checks the number of names of
+// the hostlist against opts.MaxNodes. The names are counted from the ranges of the
+// hostlist, so an error wrapping ErrTooLarge is returned before iter is called for any
+// name.
+func ExpandSlurmWithOptions(hostlist string, opts Options, iter func(s string) error) error {
+	if iter == nil {
+		return fmt.Errorf("iter function nil")
+	}
+	hosts, err := splitHostlist(hostlist)
+	if err != nil {
+		return err
+	}
+	if opts.MaxNodes > 0 {
+		var total uint64
+		for _, host := range hosts {
+			n, err := countSlurmHost(host.literal)
+			if err != nil {
+				return relocate(err, hostlist, host.offset)
+			}
+			var carry uint64
+			total, carry = bits.Add64(total, n, 0)
+			if carry != 0 || total > opts.MaxNodes {
+				return fmt.Errorf("hostlist %s, contains more than the maximum of %d nodes: %w", hostlist, opts.MaxNodes, ErrTooLarge)
+			}
+		}
+	}
+	for _, host := range hosts {
+		if err := expandSlurmHost(host.literal, iter); err != nil {
+			return relocate(err, hostlist, host.offset)
+		}
+	}
+	return nil
+}
+
+// countSlurmHost returns the number of names of a single host expression of a Slurm
+// hostlist, duplicates included, MaxUint64 when there are more than that.
+func countSlurmHost(host string) (uint64, error) {
+	total := uint64(1)
+	for pos := 0; pos < len(host); {
+		if host[pos] != '[' {
+			pos++
+			continue
+		}
+		end := pos + strings.IndexByte(host[pos:], ']')
+		ranges, err := slurmBracketRanges(host[pos+1 : end])
+		if err != nil {
+			return 0, relocate(err, host, pos+1)
+		}
+		var n uint64
+		for _, r := range ranges {
+			var carry uint64
+			n, carry = bits.Add64(n, r.End-r.Start, 1)
+			if carry != 0 {
+				return math.MaxUint64, nil
+			}
+		}
+		hi, lo := bits.Mul64(total, n)
+		if hi != 0 {
+			return math.MaxUint64, nil
+		}
+		total = lo
+		pos = end + 1
+	}
+	return total, nil
+}
+
+// expandSlurmHost calls iter per name of a single host expression of a Slurm hostlist,
+// the values of each bracket in order, with the first bracket varying slowest. Names are
+// produced by a productCursor, so no bracket is expanded before the first name.
+func expandSlurmHost(host string, iter func(s string) error) error {
+	p := &productCursor{}
+	for pos := 0; pos < len(host); {
+		if host[pos] != '[' {
+			end := strings.IndexByte(host[pos:], '[')
+			if end < 0 {
+				end = len(host) - pos
+			}
+			p.cursors = append(p.cursors, nil)
+			p.values = append(p.values, host[pos:pos+end])
+			pos += end
+			continue
+		}
+		end := pos + strings.IndexByte(host[pos:], ']')
+		ranges, err := slurmBracketRanges(host[pos+1 : end])
+		if err != nil {
+			return relocate(err, host, pos+1)
+		}
+		p.cursors = append(p.cursors, &slurmCursor{ranges: ranges})
+		p.values = append(p.values, "")
+		pos = end + 1
+	}
+	p.reset()
+
+	for name, ok := p.next(); ok; name, ok = p.next() {
+		if err := iter(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// slurmCursor iterates over the values of a bracket of a Slurm hostlist in the order
+// of its ranges, duplicates included, each padded to the padding of its range.
+type slurmCursor struct {
+	ranges []Range
+	index  int
+	value  uint64
+	done   bool
+}
+
+// reset moves the cursor back before the first value.
+func (c *slurmCursor) reset() {
+	c.index, c.value, c.done = 0, c.ranges[0].Start, false
+}
+
+// next returns the next value, false when all values have been returned.
+func (c *slurmCursor) next() (string, bool) {
+	if c.done {
+		return "", false
+	}
+	r := c.ranges[c.index]
+	value := formatPadded(c.value, r.Padding)
+	switch {
+	case c.value != r.End:
+		c.value++
+	case c.index+1 < len(c.ranges):
+		c.index++
+		c.value = c.ranges[c.index].Start
+	default:
+		c.done = true
+	}
+	return value, true
+}
+
+// slurmBracketRanges returns the elements of the contents of a bracket of a Slurm
+// hostlist as ranges, with the length of the first value of each element as padding.
+func slurmBracketRanges(ranges string) ([]Range, error) {
+	var result []Range
+	offset := 0
+	for _, element := range strings.Split(ranges, ",") {
+		lo, hi, isRange := strings.Cut(element, "-")
+		if !isRange {
+			hi = lo
+		}
+		start, err := strconv.ParseUint(lo, 10, 64)
+		if err != nil {
+			return nil, parseError(ErrBadValue, ranges, offset, element, "range [%s], contains a value that is not an integer", element)
+		}
+		end, err := strconv.ParseUint(hi, 10, 64)
+		if err != nil {
+			if strings.Contains(hi, "/") {
+				return nil, parseError(ErrBadStep, ranges, offset+len(lo)+1, hi, "range [%s], contains a step, which Slurm hostlists don't support", element)
+			}
+			return nil, parseError(ErrBadValue, ranges, offset+len(lo)+1, hi, "range [%s], ends with a value that is not an integer", element)
+		}
+		if start > end {
+			return nil, parseError(ErrReversedRange, ranges, offset, element, "range [%s], starts with a value that is greater than the end value", element)
+		}
+		result = append(result, Range{Start: start, End: end, Step: 1, Padding: len(lo)})
+		offset += len(element) + 1
+	}
+	return result, nil
+}
+
+// slurmHost is a host name split like Slurm does, into a prefix and the digits it ends with.
+type slurmHost struct {
+	prefix string
+	digits string // Trailing digits of the name, empty for names without a number.
+	value  uint64
+}
+
+// padded reports whether the number of h has a leading zero, requiring its width to be kept.
+func (h slurmHost) padded() bool {
+	return len(h.digits) > 1 && h.digits[0] == '0'
+}
+
+// combines reports whether the numbers of h and o can be part of the same range.
+func (h slurmHost) combines(o slurmHost) bool {
+	if h.padded() || o.padded() {
+		return len(h.digits) == len(o.digits)
+	}
+	return true
+}
+
+// compare orders hosts like Slurm's hostlist sort, by prefix, then by number when the
+// numbers can share a range and by width otherwise. Names without a number come first.
+func (h slurmHost) compare(o slurmHost) int {
+	if c := cmp.Compare(h.prefix, o.prefix); c != 0 {
+		return c
+	}
+	if h.digits == "" || o.digits == "" {
+		return cmp.Compare(len(h.digits), len(o.digits))
+	}
+	if !h.combines(o) {
+		return cmp.Compare(len(h.digits), len(o.digits))
+	}
+	return cmp.Compare(h.value, o.value)
+}
+
+// splitSlurmHost splits a host name into its prefix and trailing digits. Digits too
+// large to be a number are kept in the prefix.
+func splitSlurmHost(name string) slurmHost {
+	i := len(name)
+	for i > 0 && name[i-1] >= '0' && name[i-1] <= '9' {
+		i--
+	}
+	value, err := strconv.ParseUint(name[i:], 10, 64)
+	if err != nil {
+		return slurmHost{prefix: name}
+	}
+	return slurmHost{prefix: name[:i], digits: name[i:], value: value}
+}
+
+// FoldSlurm folds host names into a Slurm hostlist, producing the same hostlist as
+// 'scontrol show hostlistsorted'. Like Slurm, only the digits a name ends with are
+// folded, names are sorted by prefix and then by number, duplicates are removed, and
+// ranges of different zero padding sharing a prefix are written in the same bracket,
+// narrower numbers first, for example linux01, linux02, linux5, tux0-ib ->
+// linux[5,01-02],tux0-ib.
+func FoldSlurm(inputs []string) string {
+	hosts := make([]slurmHost, 0, len(inputs))
+	for _, name := range inputs {
+		hosts = append(hosts, splitSlurmHost(name))
+	}
+	slices.SortStableFunc(hosts, slurmHost.compare)
+
+	// Ranges of hosts sharing a prefix, as the first and last host of each range.
+	type hostRange struct{ lo, hi slurmHost }
+	var ranges []hostRange
+	for _, h := range hosts {
+		if n := len(ranges); n > 0 {
+			last := &ranges[n-1]
+			if last.hi.digits == "" || h.digits == "" {
+				if last.hi == h {
+					continue
+				}
+			} else if last.hi.prefix == h.prefix && last.hi.combines(h) && h.value-last.hi.value <= 1 {
+				last.hi = h
+				continue
+			}
+		}
+		ranges = append(ranges, hostRange{lo: h, hi: h})
+	}
+
+	var output []string
+	for i := 0; i < len(ranges); {
+		r := ranges[i]
+		if r.lo.digits == "" {
+			output = append(output, r.lo.prefix)
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(ranges) && ranges[j].lo.digits != "" && ranges[j].lo.prefix == r.lo.prefix {
+			j++
+		}
+		if j == i+1 && r.lo == r.hi {
+			output = append(output, r.lo.prefix+r.lo.digits)
+			i = j
+			continue
+		}
+		elements := make([]string, 0, j-i)
+		for _, r := range ranges[i:j] {
+			if r.lo == r.hi {
+				elements = append(elements, r.lo.digits)
+			} else {
+				elements = append(elements, r.lo.digits+"-"+r.hi.digits)
+			}
+		}
+		output = append(output, r.lo.prefix+formatRange(elements, true))
+		i = j
+	}
+	return strings.Join(output, ",")
+}
@@ -0,0 +1,158 @@
+package nodeset
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Conformance table of Slurm hostlists and the names printed for them by 'scontrol show hostnames'.
+func TestExpandSlurm(t *testing.T) {
+	tests := []struct {
+		hostlist string
+		want     string
+	}{
+		{hostlist: "linux[01-02],linux05", want: "linux01 linux02 linux05"},
+		{hostlist: "lx[10-12]", want: "lx10 lx11 lx12"},
+		{hostlist: "tux[0-3]-ib", want: "tux0-ib tux1-ib tux2-ib tux3-ib"},
+		{hostlist: "rack[0-1]_blade[0-1]", want: "rack0_blade0 rack0_blade1 rack1_blade0 rack1_blade1"},
+		{hostlist: "node[3,1-2]", want: "node3 node1 node2"},
+		{hostlist: "node2,node1,node2", want: "node2 node1 node2"},
+		{hostlist: "node[1,01,001]", want: "node1 node01 node001"},
+		{hostlist: "node[01,5-6]", want: "node01 node5 node6"},
+		{hostlist: "node[8-10]", want: "node8 node9 node10"},
+		{hostlist: "node[001-10]", want: "node001 node002 node003 node004 node005 node006 node007 node008 node009 node010"},
+		{hostlist: "node[98-100]", want: "node98 node99 node100"},
+		{hostlist: "a1 b[2-3],,c4", want: "a1 b2 b3 c4"},
+		{hostlist: "[1-2]node", want: "1node 2node"},
+		{hostlist: "n[2-3,1-2]x[1,1]", want: "n2x1 n2x1 n3x1 n3x1 n1x1 n1x1 n2x1 n2x1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.hostlist, func(t *testing.T) {
+			var got []string
+			err := ExpandSlurm(tt.hostlist, func(s string) error {
+				got = append(got, s)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ExpandSlurm() error = %v", err)
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("ExpandSlurm() = %v, want %v", strings.Join(got, " "), tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandSlurmStop(t *testing.T) {
+	stop := errors.New("stop")
+	var got []string
+	err := ExpandSlurm("n[0-20000000]x[1-2]", func(s string) error {
+		got = append(got, s)
+		if len(got) == 3 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("ExpandSlurm() error = %v, want stop", err)
+	}
+	if want := []string{"n0x1", "n0x2", "n1x1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ExpandSlurm() = %v, want %v", got, want)
+	}
+}
+
+func TestExpandSlurmErrors(t *testing.T) {
+	tests := []struct {
+		hostlist string
+		kind     error
+		offset   int
+	}{
+		{hostlist: "node[1-4/2]", kind: ErrBadStep, offset: 7},
+		{hostlist: "node[4-1]", kind: ErrReversedRange, offset: 5},
+		{hostlist: "node[1-2", kind: ErrUnbalancedBracket, offset: 4},
+		{hostlist: "node1-2]", kind: ErrUnbalancedBracket, offset: 7},
+		{hostlist: "node[1[2]]", kind: ErrNestedBracket, offset: 6},
+		{hostlist: "a1,node[x]", kind: ErrBadValue, offset: 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.hostlist, func(t *testing.T) {
+			err := ExpandSlurm(tt.hostlist, func(s string) error { return nil })
+			if !errors.Is(err, tt.kind) {
+				t.Fatalf("ExpandSlurm() error = %v, want %v", err, tt.kind)
+			}
+			var pe *ParseError
+			if errors.As(err, &pe) && pe.Offset != tt.offset {
+				t.Errorf("ExpandSlurm() offset = %d, want %d", pe.Offset, tt.offset)
+			}
+		})
+	}
+}
+
+func TestExpandSlurmWithOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		hostlist string
+		maxNodes uint64
+		want     []string
+		wantErr  error
+	}{
+		{name: "Within limit", hostlist: "node[1-2],node1", maxNodes: 3, want: []string{"node1", "node2", "node1"}},
+		{name: "Duplicates over limit", hostlist: "node[1-2],node1", maxNodes: 2, wantErr: ErrTooLarge},
+		{name: "Large bracket", hostlist: "id[1-10000000000]", maxNodes: 1000, wantErr: ErrTooLarge},
+		{name: "Product not countable", hostlist: "a[0-18446744073709551615]b[1-2]", maxNodes: 1000, wantErr: ErrTooLarge},
+		{name: "Invalid hostlist", hostlist: "a1,node[x]", maxNodes: 1000, wantErr: ErrBadValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := ExpandSlurmWithOptions(tt.hostlist, Options{MaxNodes: tt.maxNodes}, func(s string) error {
+				got = append(got, s)
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExpandSlurmWithOptions() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandSlurmWithOptions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Conformance table of host names and the hostlist printed for them by 'scontrol show hostlistsorted'.
+func TestFoldSlurm(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs []string
+		want   string
+	}{
+		{name: "Single host", inputs: []string{"node5"}, want: "node5"},
+		{name: "Range", inputs: []string{"node3", "node1", "node2"}, want: "node[1-3]"},
+		{name: "Duplicates", inputs: []string{"node1", "node1", "node2"}, want: "node[1-2]"},
+		{name: "Ranges across widths", inputs: []string{"node9", "node10", "node12"}, want: "node[9-10,12]"},
+		{name: "Padded and unpadded", inputs: []string{"linux5", "linux02", "linux01"}, want: "linux[5,01-02]"},
+		{name: "Documented example", inputs: []string{"linux01", "linux02", "linux5", "tux0-ib"}, want: "linux[5,01-02],tux0-ib"},
+		{name: "Prefixes sorted", inputs: []string{"tux1", "lx2", "lx1"}, want: "lx[1-2],tux1"},
+		{name: "Only trailing digits fold", inputs: []string{"rack1n1", "rack1n2", "rack2n1"}, want: "rack1n[1-2],rack2n1"},
+		{name: "Names without numbers", inputs: []string{"tux1-ib", "login", "tux0-ib"}, want: "login,tux0-ib,tux1-ib"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FoldSlurm(tt.inputs)
+			if got != tt.want {
+				t.Errorf("FoldSlurm() = %v, want %v", got, tt.want)
+			}
+
+			// The folded hostlist expands back to the sorted and deduplicated names.
+			var names []string
+			if err := ExpandSlurm(got, func(s string) error { names = append(names, s); return nil }); err != nil {
+				t.Fatalf("ExpandSlurm() error = %v", err)
+			}
+			if want := NewNodeSet(tt.inputs...); !reflect.DeepEqual(NewNodeSet(names...).Fold(), want.Fold()) || len(names) != int(want.Len()) {
+				t.Errorf("ExpandSlurm(FoldSlurm()) = %v, want the names of %v", names, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package nodeset
+
+import (
+	"cmp"
+	"iter"
+	"slices"
+	"strings"
+)
+
+// All returns an iterator over the node names of a node pattern, in the same order as
+// Expand. Names are produced as the loop consumes them, so breaking out of the loop
+// stops the expansion without the sentinel error Expand would need. An invalid pattern
+// yields a single empty name along with the error.
+//
+//	for name, err := range nodeset.All("node[1-1000]") {
+//		if err != nil {
+//			return err
+//		}
+//		if name == wanted {
+//			break
+//		}
+//	}
+func All(pattern string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		names, err := Names(pattern)
+		if err != nil {
+			yield("", err)
+			return
+		}
+		for name := range names {
+			if !yield(name, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Names returns an iterator over the node names of a node pattern, in the same order
+// as Expand. Unlike All, the pattern is parsed before Names returns, so the iterator
+// itself can't fail, which suits pulling names one at a time with iter.Pull:
+//
+//	names, err := nodeset.Names("node[1-1000]")
+//	if err != nil {
+//		return err
+//	}
+//	next, stop := iter.Pull(names)
+//	defer stop()
+func Names(pattern string) (iter.Seq[string], error) {
+	p, err := Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return p.All(), nil
+}
+
+// All returns an iterator over the node names of ns, a folded pattern at a time in
+// ascending order of their first name. Names are produced as the loop consumes them.
+//
+// The order differs from Fold and String, which sort the formatted patterns in
+// descending order as the Fold function always has. Following it would mean formatting
+// every pattern of ns before yielding a name, and would list the names of node[1-2]
+// before those of a[3-4], while All walks names the way Expand lists them.
+func (ns *NodeSet) All() iter.Seq[string] {
+	type namedBox struct {
+		first string
+		box   foldedBox
+	}
+	var boxes []namedBox
+	for _, fb := range foldBoxes(ns.groupMap()) {
+		boxes = append(boxes, namedBox{first: fb.first(), box: fb})
+	}
+	slices.SortFunc(boxes, func(x, y namedBox) int {
+		return cmp.Compare(x.first, y.first)
+	})
+
+	return func(yield func(string) bool) {
+		for _, nb := range boxes {
+			if !nb.box.names(yield) {
+				return
+			}
+		}
+	}
+}
+
+// first returns the first name of fb, the first name yielded by names.
+func (fb foldedBox) first() string {
+	var sb strings.Builder
+	for i, widths := range fb.components {
+		w := byFirstValue(widths)[0]
+		sb.WriteString(fb.literals[i])
+		sb.WriteString(w.format.format(w.values[0].lo))
+	}
+	sb.WriteString(fb.literals[len(fb.literals)-1])
+	return sb.String()
+}
+
+// names calls yield with each name of the box b of shape s, in lexicographic order of
+// the components. It returns false if yield returned false.
+func (s shape) names(b box, yield func(string) bool) bool {
+	return s.folded(b).names(yield)
+}
+
+// names calls yield with each name of fb, in lexicographic order of the components,
+// the values of each component ordered like formatWidths writes them. It returns false
+// if yield returned false.
+func (fb foldedBox) names(yield func(string) bool) bool {
+	values := make([]string, len(fb.components))
+
+	var walk func(dim int) bool
+	walk = func(dim int) bool {
+		if dim == len(fb.components) {
+			var sb strings.Builder
+			for i, v := range values {
+				sb.WriteString(fb.literals[i])
+				sb.WriteString(v)
+			}
+			sb.WriteString(fb.literals[len(fb.literals)-1])
+			return yield(sb.String())
+		}
+		for _, w := range byFirstValue(fb.components[dim]) {
+			for v := range w.values.values() {
+				values[dim] = w.format.format(v)
+				if !walk(dim + 1) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return walk(0)
+}
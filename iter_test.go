@@ -0,0 +1,140 @@
+package nodeset
+
+import (
+	"iter"
+	"reflect"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		limit   int
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "Range",
+			pattern: "node[1-3]",
+			limit:   -1,
+			want:    []string{"node1", "node2", "node3"},
+		},
+		{
+			name:    "Break after two names",
+			pattern: "rack[1-2]node[3-4]",
+			limit:   2,
+			want:    []string{"rack1node3", "rack1node4"},
+		},
+		{
+			name:    "Large range",
+			pattern: "node[1-100000000]",
+			limit:   1,
+			want:    []string{"node1"},
+		},
+		{
+			name:    "Largest range",
+			pattern: "node[0-18446744073709551615]x[1-2]",
+			limit:   3,
+			want:    []string{"node0x1", "node0x2", "node1x1"},
+		},
+		{
+			name:    "Invalid pattern",
+			pattern: "node[1-",
+			limit:   -1,
+			want:    []string{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			var gotErr error
+			for name, err := range All(tt.pattern) {
+				if err != nil {
+					gotErr = err
+					break
+				}
+				if len(got) == tt.limit {
+					break
+				}
+				got = append(got, name)
+			}
+			if (gotErr != nil) != tt.wantErr {
+				t.Errorf("All() error = %v, wantErr %v", gotErr, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("All() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamesPull(t *testing.T) {
+	names, err := Names("node[1-2]")
+	if err != nil {
+		t.Fatalf("Names() error = %v", err)
+	}
+	next, stop := iter.Pull(names)
+	defer stop()
+
+	for _, want := range []string{"node1", "node2"} {
+		if got, ok := next(); !ok || got != want {
+			t.Errorf("next() = %v, %v, want %v, true", got, ok, want)
+		}
+	}
+	if _, ok := next(); ok {
+		t.Errorf("next() ok = true after the last name")
+	}
+
+	if _, err := Names(""); err == nil {
+		t.Errorf("Names() error = nil for an empty pattern")
+	}
+}
+
+func TestNodeSetAll(t *testing.T) {
+	ns, err := Parse("r[1-2]n[1-2],gpu[08-10]")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	got := []string{}
+	for name := range ns.All() {
+		got = append(got, name)
+	}
+	want := []string{"gpu08", "gpu09", "gpu10", "r1n1", "r1n2", "r2n1", "r2n2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("All() = %v, want %v", got, want)
+	}
+
+	got = []string{}
+	for name := range ns.All() {
+		if len(got) == 3 {
+			break
+		}
+		got = append(got, name)
+	}
+	if !reflect.DeepEqual(got, want[:3]) {
+		t.Errorf("All() with break = %v, want %v", got, want[:3])
+	}
+}
+
+func TestNodeSetAllLargeStepRange(t *testing.T) {
+	ns, err := Parse("id[1-100000000/2],id0")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	got := []string{}
+	for name := range ns.All() {
+		if len(got) == 3 {
+			break
+		}
+		got = append(got, name)
+	}
+	want := []string{"id0", "id1", "id3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("All() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,117 @@
+package nodeset
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrNoJob is returned when the environment doesn't describe the nodes of a batch job.
+var ErrNoJob = errors.New("no batch job node list in environment")
+
+// JobNodes returns the nodes of the current batch job, see JobNodeNames.
+func JobNodes() (*NodeSet, error) {
+	names, err := JobNodeNames()
+	if err != nil {
+		return nil, err
+	}
+	return NewNodeSet(names...), nil
+}
+
+// JobNodeNames returns the node names of the current batch job, read from the
+// environment set by the job scheduler. The first of the following that is set is used:
+// SLURM_JOB_NODELIST or SLURM_NODELIST - a Slurm hostlist, see ExpandSlurm
+// PBS_NODEFILE - a file of PBS or Torque listing a node per line
+// LSB_MCPU_HOSTS - pairs of node names and slot counts of LSF, like 'node1 4 node2 4'
+// LSB_HOSTS - node names of LSF, separated by spaces
+// COBALT_NODEFILE - a file of Cobalt listing a node per line
+// Names are returned in the order listed, and nodes listed once per slot, like in
+// a PBS node file or LSB_HOSTS, are repeated. An error wrapping ErrNoJob is returned
+// when none of the variables is set.
+func JobNodeNames() ([]string, error) {
+	for _, name := range []string{"SLURM_JOB_NODELIST", "SLURM_NODELIST"} {
+		if value := os.Getenv(name); value != "" {
+			var names []string
+			err := ExpandSlurm(value, func(s string) error { names = append(names, s); return nil })
+			if err != nil {
+				return nil, fmt.Errorf("%s, %w", name, err)
+			}
+			return names, nil
+		}
+	}
+	for _, name := range []string{"PBS_NODEFILE", "COBALT_NODEFILE"} {
+		if path := os.Getenv(name); path != "" {
+			names, err := readNodeFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("%s, %w", name, err)
+			}
+			return names, nil
+		}
+	}
+	if value := os.Getenv("LSB_MCPU_HOSTS"); value != "" {
+		names, err := parseSlotPairs(value)
+		if err != nil {
+			return nil, fmt.Errorf("LSB_MCPU_HOSTS, %w", err)
+		}
+		return names, nil
+	}
+	if value := os.Getenv("LSB_HOSTS"); value != "" {
+		return strings.Fields(value), nil
+	}
+	return nil, ErrNoJob
+}
+
+// readNodeFile returns the node names of the node file at path, see ParseNodeFile.
+func readNodeFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseNodeFile(f)
+}
+
+// ParseNodeFile returns the node names of a node file listing a node per line, like the
+// PBS_NODEFILE of a PBS job. Names are returned in order, including repeated names, while
+// blank lines and comments starting with '#' are skipped.
+func ParseNodeFile(r io.Reader) ([]string, error) {
+	var names []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if name := strings.TrimSpace(line); name != "" {
+			names = append(names, name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// parseSlotPairs returns the node names of a list of node names and slot counts, like
+// 'node1 4 node2 4', with each name repeated per slot.
+func parseSlotPairs(value string) ([]string, error) {
+	fields := strings.Fields(value)
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("node %s, is missing a slot count", fields[len(fields)-1])
+	}
+	var names []string
+	for i := 0; i < len(fields); i += 2 {
+		slots, err := strconv.Atoi(fields[i+1])
+		if err != nil || slots < 0 {
+			return nil, fmt.Errorf("node %s, slot count %s is not an integer", fields[i], fields[i+1])
+		}
+		for range slots {
+			names = append(names, fields[i])
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,113 @@
+package nodeset
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestJobNodeNames(t *testing.T) {
+	nodeFile := filepath.Join(t.TempDir(), "nodefile")
+	if err := os.WriteFile(nodeFile, []byte("node2\nnode2\n\nnode1 # head node\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "Slurm job node list",
+			env:  map[string]string{"SLURM_JOB_NODELIST": "node[3,1-2]", "PBS_NODEFILE": nodeFile},
+			want: []string{"node3", "node1", "node2"},
+		},
+		{
+			name: "Slurm node list",
+			env:  map[string]string{"SLURM_NODELIST": "tux[0-1]-ib"},
+			want: []string{"tux0-ib", "tux1-ib"},
+		},
+		{
+			name: "PBS node file",
+			env:  map[string]string{"PBS_NODEFILE": nodeFile},
+			want: []string{"node2", "node2", "node1"},
+		},
+		{
+			name: "Cobalt node file",
+			env:  map[string]string{"COBALT_NODEFILE": nodeFile},
+			want: []string{"node2", "node2", "node1"},
+		},
+		{
+			name: "LSF hosts with slot counts",
+			env:  map[string]string{"LSB_MCPU_HOSTS": "node1 2 node2 1", "LSB_HOSTS": "node9"},
+			want: []string{"node1", "node1", "node2"},
+		},
+		{
+			name: "LSF hosts",
+			env:  map[string]string{"LSB_HOSTS": "node1 node1 node2"},
+			want: []string{"node1", "node1", "node2"},
+		},
+		{
+			name:    "No job",
+			env:     map[string]string{},
+			wantErr: ErrNoJob,
+		},
+		{
+			name:    "Invalid Slurm node list",
+			env:     map[string]string{"SLURM_JOB_NODELIST": "node[1-2"},
+			wantErr: ErrUnbalancedBracket,
+		},
+		{
+			name:    "Missing node file",
+			env:     map[string]string{"PBS_NODEFILE": filepath.Join(t.TempDir(), "missing")},
+			wantErr: os.ErrNotExist,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"SLURM_JOB_NODELIST", "SLURM_NODELIST", "PBS_NODEFILE", "LSB_MCPU_HOSTS", "LSB_HOSTS", "COBALT_NODEFILE"} {
+				t.Setenv(name, tt.env[name])
+			}
+			got, err := JobNodeNames()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("JobNodeNames() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("JobNodeNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobNodeNamesSlotCounts(t *testing.T) {
+	for _, value := range []string{"node1 x", "node1 -1", "node1 2 node2"} {
+		t.Run(value, func(t *testing.T) {
+			t.Setenv("SLURM_JOB_NODELIST", "")
+			t.Setenv("SLURM_NODELIST", "")
+			t.Setenv("PBS_NODEFILE", "")
+			t.Setenv("COBALT_NODEFILE", "")
+			t.Setenv("LSB_MCPU_HOSTS", value)
+			if got, err := JobNodeNames(); err == nil {
+				t.Errorf("JobNodeNames() = %v, want error", got)
+			}
+		})
+	}
+}
+
+func TestJobNodes(t *testing.T) {
+	t.Setenv("SLURM_JOB_NODELIST", "")
+	t.Setenv("SLURM_NODELIST", "")
+	t.Setenv("PBS_NODEFILE", "")
+	t.Setenv("COBALT_NODEFILE", "")
+	t.Setenv("LSB_MCPU_HOSTS", "node2 4 node1 4 node3 4")
+	got, err := JobNodes()
+	if err != nil {
+		t.Fatalf("JobNodes() error = %v", err)
+	}
+	if want := "node[1-3]"; got.String() != want {
+		t.Errorf("JobNodes() = %v, want %v", got, want)
+	}
+}
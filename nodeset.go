@@ -0,0 +1,993 @@
+package nodeset
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"math"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// NodeSet is an immutable set of node names. Names are held folded, as Cartesian
+// products of numeric ranges per distinct name shape, so a set like rack[1-48]node[1-128]
+// is stored as a single product rather than 6144 strings, and step ranges like
+// id[1-10000000000/2] keep their step rather than listing their values. Ranges of
+// letters and hexadecimal values, like p[a-zzzz] or bmc-[0x0000-0xffff], are kept as
+// ranges too, unless next to other such ranges, see splitNotations. The zero value is
+// an empty set.
+type NodeSet struct {
+	groups map[string]*shapeGroup
+}
+
+// NewNodeSet returns a NodeSet containing the given node names.
+func NewNodeSet(names ...string) *NodeSet {
+	ns := &NodeSet{groups: make(map[string]*shapeGroup)}
+	seen := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		ns.add(splitShape(name))
+	}
+	ns.normalize()
+	return ns
+}
+
+// Parse returns the NodeSet of a node set pattern like 'node[1-2],gpu[01-04]'. Each
+// operand supports the same syntax as Expand, and operands are combined with the
+// following operators:
+// Union - node[1-4],node[8-9]
+// Difference - node[1-10]!node[3-4]
+// Intersection - node[1-10]&node[5-20]
+// Symmetric difference - node[1-10]^node[5-20]
+// All operators have the same precedence and are evaluated from left to right, so
+// 'node[1-4],node[8-9]!node[2-8]' results in node[1,9]. Operands starting with '@'
+// are group references, which are only resolved by ParseWithOptions.
+func Parse(pattern string) (*NodeSet, error) {
+	return ParseWithOptions(pattern, Options{})
+}
+
+// splitOperators splits pattern on the operators supported by Parse, except for when
+// within square brackets or braces. The supplied fn is called per operand in order, along with
+// the operator preceding the operand, a comma for the first operand.
+func splitOperators(pattern string, fn func(op byte, operand string) error) error {
+	if pattern == "" {
+		return fmt.Errorf("empty pattern")
+	}
+
+	var op byte = ','
+	start := 0
+	inBrackets := 0
+
+	for i := 0; i <= len(pattern); i++ {
+		if i < len(pattern) {
+			switch pattern[i] {
+			case '[', '{':
+				inBrackets++
+				continue
+			case ']', '}':
+				inBrackets--
+				continue
+			case ',', '!', '&', '^':
+				if inBrackets > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+
+		operand := pattern[start:i]
+		if operand == "" {
+			if start == 0 {
+				return parseError(ErrMissingOperand, pattern, i, pattern[i:i+1], "operator '%c' is missing a left operand", pattern[i])
+			}
+			return parseError(ErrMissingOperand, pattern, start-1, string(op), "operator '%c' is missing a right operand", op)
+		}
+		if err := fn(op, operand); err != nil {
+			return relocate(err, pattern, start)
+		}
+
+		if i < len(pattern) {
+			op = pattern[i]
+			start = i + 1
+		}
+	}
+	return nil
+}
+
+// parseOperand returns the NodeSet of a single node pattern without operators.
+func parseOperand(pattern string) (*NodeSet, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+	segments, err := splitPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return segmentsNodeSet(pattern, segments)
+}
+
+// segmentsNodeSet returns the NodeSet of the names produced by segments of pattern.
+func segmentsNodeSet(pattern string, segments []segment) (*NodeSet, error) {
+	products, err := parseProducts(pattern, segments)
+	if err != nil {
+		return nil, err
+	}
+	ns := &NodeSet{groups: make(map[string]*shapeGroup)}
+	for _, components := range products {
+		ns.add(newShape(components))
+	}
+	ns.normalize()
+	return ns, nil
+}
+
+// apply combines ns and other with one of the Parse operators.
+func (ns *NodeSet) apply(op byte, other *NodeSet) *NodeSet {
+	switch op {
+	case '!':
+		return ns.Difference(other)
+	case '&':
+		return ns.Intersection(other)
+	case '^':
+		return ns.SymmetricDifference(other)
+	default:
+		return ns.Union(other)
+	}
+}
+
+// parseProducts returns the component sequences of each distinct shape covered by the
+// segments of a single node pattern. Digits next to each other, like the 'x100' prefix
+// in 'x100[1-2]', are combined into a single component to match how node names are
+// split. Values of brackets like [a-c] or [0x0a-0x0f] are kept as components written
+// in their notation where splitNotations allows it, and alternations are parsed one
+// alternative at a time.
+func parseProducts(pattern string, segments []segment) ([][]component, error) {
+	var products [][]component
+	for _, segments := range spellAlternations(segments) {
+		for _, segments := range splitNotations(segments) {
+			p, err := segmentProducts(pattern, segments)
+			if err != nil {
+				return nil, err
+			}
+			products = append(products, p...)
+		}
+	}
+	return products, nil
+}
+
+// spellAlternations returns the segment sequences covering the same names as segments,
+// one per combination of the alternatives of its alternations.
+func spellAlternations(segments []segment) [][]segment {
+	sequences := [][]segment{nil}
+	for _, seg := range segments {
+		if seg.alternatives == nil {
+			for i := range sequences {
+				sequences[i] = slices.Concat(sequences[i], []segment{seg})
+			}
+			continue
+		}
+		var next [][]segment
+		for _, alternative := range seg.alternatives {
+			for _, spelled := range spellAlternations(alternative) {
+				for _, sequence := range sequences {
+					next = append(next, slices.Concat(sequence, spelled))
+				}
+			}
+		}
+		sequences = next
+	}
+	return sequences
+}
+
+// splitNotations returns the segment sequences covering the same names as segments,
+// where each bracket only holds values of a single notation. Values not written in
+// decimal are kept as ranges as long as names can be split back into the values of
+// each bracket: a run of letters and digits may hold a single bracket not written in
+// decimal, next to decimal values for letters, or on its own for hexadecimal values,
+// which hold digits. The values of other such brackets are replaced by literal
+// segments, like the letters of p[a-b][a-b], which could be split in several ways.
+func splitNotations(segments []segment) [][]segment {
+	var result [][]segment
+	for _, sequence := range segmentProduct(segments, func(_ int, seg segment) []segment {
+		if seg.ranges == nil {
+			return []segment{seg}
+		}
+		var alternatives []segment
+		for _, r := range seg.ranges {
+			i := slices.IndexFunc(alternatives, func(a segment) bool { return a.ranges[0].Notation == r.Notation })
+			if i < 0 {
+				alternatives = append(alternatives, segment{offset: seg.offset})
+				i = len(alternatives) - 1
+			}
+			alternatives[i].ranges = append(alternatives[i].ranges, r)
+		}
+		return alternatives
+	}) {
+		spelled := spelledSegments(sequence)
+		result = append(result, segmentProduct(sequence, func(i int, seg segment) []segment {
+			if !spelled[i] || seg.ranges == nil || seg.ranges[0].Notation == Decimal {
+				return []segment{seg}
+			}
+			var literals []segment
+			for _, v := range rangeValues(seg.ranges) {
+				literals = append(literals, segment{literal: v, offset: seg.offset})
+			}
+			return literals
+		})...)
+	}
+	return result
+}
+
+// spelledSegments reports for each segment of segments whether it's a bracket not
+// written in decimal whose values have to be replaced by literal segments, see
+// splitNotations. Each bracket holds values of a single notation.
+func spelledSegments(segments []segment) []bool {
+	spelled := make([]bool, len(segments))
+	var brackets []int
+	decimal, hex := false, false
+	flush := func() {
+		if len(brackets) > 1 || hex && decimal {
+			for _, i := range brackets {
+				spelled[i] = true
+			}
+		}
+		brackets, decimal, hex = nil, false, false
+	}
+	for i, seg := range segments {
+		switch {
+		case seg.ranges == nil:
+			for j := 0; j < len(seg.literal); j++ {
+				if !isAlnum(seg.literal[j]) {
+					flush()
+				} else if isDigit(seg.literal[j]) {
+					decimal = true
+				}
+			}
+		case seg.ranges[0].Notation == Decimal:
+			decimal = true
+		default:
+			brackets = append(brackets, i)
+			hex = hex || seg.ranges[0].Notation.hex()
+		}
+	}
+	flush()
+	return spelled
+}
+
+// segmentProduct returns a segment sequence per combination of the alternatives
+// returned by alternatives for each segment of segments, given with its index.
+func segmentProduct(segments []segment, alternatives func(i int, seg segment) []segment) [][]segment {
+	parts := make([][]segment, len(segments))
+	for i, seg := range segments {
+		parts[i] = alternatives(i, seg)
+	}
+	if len(parts) == 0 {
+		return [][]segment{segments}
+	}
+
+	var result [][]segment
+	lens := func(i int) int { return len(parts[i]) }
+	for ix := make([]int, len(parts)); ix[0] < lens(0); nextIndex(ix, lens) {
+		sequence := make([]segment, len(ix))
+		for j, k := range ix {
+			sequence[j] = parts[j][k]
+		}
+		result = append(result, sequence)
+	}
+	return result
+}
+
+// segmentProducts returns the component sequences of each distinct shape covered by
+// segments of pattern, where each bracket only holds values of a single notation.
+func segmentProducts(pattern string, segments []segment) ([][]component, error) {
+	// Each part holds either a single literal component, or one digit component per format.
+	var parts [][]component
+	decimal := func(p []component) bool { return p[0].digits && p[0].format.notation == Decimal }
+	appendPart := func(p []component, offset int) error {
+		n := len(parts)
+		if n == 0 {
+			parts = append(parts, p)
+			return nil
+		}
+		prev := parts[n-1]
+		switch {
+		case !prev[0].digits && !p[0].digits:
+			parts[n-1] = []component{{literal: prev[0].literal + p[0].literal}}
+		case !decimal(prev) && prev[0].digits || !decimal(p) && p[0].digits:
+			// Values not written in decimal are never combined, see splitNotations.
+			parts = append(parts, p)
+		case decimal(prev) && decimal(p):
+			joined, err := concatDigits(prev, p)
+			if err != nil {
+				return parseError(ErrOutOfRange, pattern, offset, pattern[offset:], "%v", err)
+			}
+			parts[n-1] = joined
+		case endsWithDigit(prev[0].literal) || startsWithDigit(p[0].literal):
+			// Digits too large to be folded are kept as literals, but can't be combined with a range.
+			return parseError(ErrOutOfRange, pattern, offset, pattern[offset:], "contains digits next to a range that are out of range")
+		default:
+			parts = append(parts, p)
+		}
+		return nil
+	}
+	for _, seg := range segments {
+		if seg.ranges != nil {
+			if err := appendPart(rangeComponents(seg.ranges), seg.offset); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		offset := seg.offset
+		for _, element := range splitOnDigits(seg.literal) {
+			c := component{literal: element}
+			if val, err := strconv.ParseUint(element, 10, 64); err == nil {
+				c = component{digits: true, format: valueFormat{padding: digitPadding(element)}, values: singleRange(val)}
+			}
+			if err := appendPart([]component{c}, offset); err != nil {
+				return nil, err
+			}
+			offset += len(element)
+		}
+	}
+
+	// Cartesian product of the alternative components of each part.
+	if len(parts) == 0 {
+		return [][]component{{}}, nil
+	}
+	var products [][]component
+	lens := func(i int) int { return len(parts[i]) }
+	for ix := make([]int, len(parts)); ix[0] < lens(0); nextIndex(ix, lens) {
+		components := make([]component, len(ix))
+		for j, k := range ix {
+			components[j] = parts[j][k]
+		}
+		products = append(products, components)
+	}
+	return products, nil
+}
+
+// rangeComponents returns a digit component per format of the ranges of a bracket,
+// which are all written in the same notation.
+func rangeComponents(ranges []Range) []component {
+	byFormat := canonicalRanges(ranges)
+	formats := slices.SortedFunc(maps.Keys(byFormat), func(x, y valueFormat) int {
+		return cmp.Compare(x.padding, y.padding)
+	})
+	components := make([]component, len(formats))
+	for i, f := range formats {
+		components[i] = component{digits: true, format: f, values: byFormat[f]}
+	}
+	return components
+}
+
+// digitComponents returns a digit component per zero padding of intervals, ordered by padding.
+func digitComponents(intervals map[int][]interval) []component {
+	paddings := make([]int, 0, len(intervals))
+	for padding := range intervals {
+		paddings = append(paddings, padding)
+	}
+	slices.Sort(paddings)
+
+	components := make([]component, len(paddings))
+	for i, padding := range paddings {
+		components[i] = component{digits: true, format: valueFormat{padding: padding}, values: rangeSetOf(intervals[padding])}
+	}
+	return components
+}
+
+// concatDigits returns the digit components of every value of a followed by every value
+// of b, like 'x1[0-1]' resulting in the values 10 and 11. The values are built per pair
+// of intervals of a and b by concatIntervals, without listing them.
+func concatDigits(a, b []component) ([]component, error) {
+	errRange := fmt.Errorf("contains adjacent digits that are out of range")
+	intervals := make(map[int][]interval)
+
+	for _, x := range a {
+		for _, left := range x.values {
+			for _, y := range b {
+				for _, right := range y.values {
+					for length, values := range digitLengths(right, y.format.padding) {
+						for padding, prefixes := range leadingPaddings(left, x.format.padding, length) {
+							joined, ok := concatIntervals(prefixes, values, length)
+							if !ok {
+								return nil, errRange
+							}
+							intervals[padding] = append(intervals[padding], joined...)
+						}
+					}
+				}
+			}
+		}
+	}
+	return digitComponents(intervals), nil
+}
+
+// leadingPaddings splits the values of iv, written with padding, by the zero padding of
+// the values they lead when followed by length more digits, returning a map of that
+// padding to the values of iv leading values of that padding.
+func leadingPaddings(iv interval, padding, length int) map[int]interval {
+	if padding > 0 {
+		return map[int]interval{padding + length: iv}
+	}
+	if iv.lo != 0 {
+		return map[int]interval{0: iv}
+	}
+	// A leading 0 pads the digits following it, like 0 and 5 resulting in 05.
+	result := map[int]interval{1 + length: {lo: 0, hi: 0}}
+	if iv.hi != 0 {
+		result[0] = newInterval(iv.stride(), iv.hi, iv.step)
+	}
+	return result
+}
+
+// maxConcatIntervals is the number of values of an interval followed by other digits up
+// to which an interval is built per value, see concatIntervals.
+const maxConcatIntervals = 1 << 20
+
+// concatIntervals returns the values of x followed by the values of w, which are length
+// digits long, false if they overflow an uint64. Following contiguous values by every
+// value of their length results in a single interval, like 1-5 and 0-9 resulting in
+// 10-59. Otherwise an interval is returned per value of x, like 1-2 and 0-5 resulting
+// in 10-15 and 20-25, or per value of w when x holds more than both w and
+// maxConcatIntervals values, like 1-5 and 0-1 resulting in 10-50/10 and 11-51/10.
+// Intervals without a step are preferred, as uniting intervals with a step that span
+// each other takes much longer.
+func concatIntervals(x, w interval, length int) ([]interval, bool) {
+	mult, ok := pow10(length)
+	if !ok {
+		return nil, false
+	}
+	hi, base := bits.Mul64(x.hi, mult)
+	if _, carry := bits.Add64(base, w.hi, 0); hi != 0 || carry != 0 {
+		return nil, false
+	}
+	if x.step == 0 && w.step == 0 && w.lo == 0 && w.hi == mult-1 {
+		return []interval{{lo: x.lo * mult, hi: x.hi*mult + w.hi}}, true
+	}
+
+	var result []interval
+	if nx, nw := (x.hi-x.lo)/x.stride(), (w.hi-w.lo)/w.stride(); nx <= nw || nx < maxConcatIntervals {
+		for v := range x.values() {
+			result = append(result, newInterval(v*mult+w.lo, v*mult+w.hi, w.step))
+		}
+	} else {
+		for v := range w.values() {
+			result = append(result, newInterval(x.lo*mult+v, x.hi*mult+v, x.stride()*mult))
+		}
+	}
+	return result, true
+}
+
+// digitLengths splits the values of iv by their length when formatted with padding,
+// returning a map of length to the values of that length.
+func digitLengths(iv interval, padding int) map[int]interval {
+	if padding > 0 {
+		return map[int]interval{padding: iv}
+	}
+	result := make(map[int]interval)
+	lo := uint64(0)
+	for length := 1; lo <= iv.hi; length++ {
+		hi := uint64(math.MaxUint64)
+		if bound, ok := pow10(length); ok {
+			hi = bound - 1
+		}
+		if values, ok := iv.intersect(interval{lo: lo, hi: hi}); ok {
+			result[length] = values
+		}
+		if hi == math.MaxUint64 {
+			break
+		}
+		lo = hi + 1
+	}
+	return result
+}
+
+// endsWithDigit reports whether s ends with an ASCII digit.
+func endsWithDigit(s string) bool {
+	return s != "" && s[len(s)-1] >= '0' && s[len(s)-1] <= '9'
+}
+
+// startsWithDigit reports whether s starts with an ASCII digit.
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+// Union returns a new NodeSet of the nodes in either ns or other.
+func (ns *NodeSet) Union(other *NodeSet) *NodeSet {
+	result := ns.clone()
+	for key, group := range other.groupMap() {
+		for _, b := range group.boxes {
+			result.add(key, group.shape, b)
+		}
+	}
+	result.normalizeBoxes()
+	result.reconcile(other.groupMap())
+	return result
+}
+
+// Intersection returns a new NodeSet of the nodes in both ns and other.
+func (ns *NodeSet) Intersection(other *NodeSet) *NodeSet {
+	result := &NodeSet{groups: make(map[string]*shapeGroup)}
+	otherGroups := other.groupMap()
+	for key, group := range ns.groupMap() {
+		otherGroup, ok := otherGroups[key]
+		if !ok {
+			continue
+		}
+		for _, a := range group.boxes {
+			for _, b := range otherGroup.boxes {
+				if c, ok := a.intersect(b); ok {
+					result.add(key, group.shape, c)
+				}
+			}
+		}
+	}
+	// Names held by groups of different shapes, see reconcile.
+	groups := ns.groupMap()
+	for _, pair := range sharingPairs(groups, otherGroups) {
+		g, h := groups[pair[0]], otherGroups[pair[1]]
+		owner, boxes := overlap(g, h)
+		key := pair[0]
+		if owner == h {
+			key = pair[1]
+		}
+		for _, b := range boxes {
+			result.add(key, owner.shape, b)
+		}
+	}
+	result.normalizeBoxes()
+	return result
+}
+
+// Difference returns a new NodeSet of the nodes in ns that aren't in other.
+func (ns *NodeSet) Difference(other *NodeSet) *NodeSet {
+	result := &NodeSet{groups: make(map[string]*shapeGroup)}
+	otherGroups := other.groupMap()
+	for key, group := range ns.groupMap() {
+		boxes := group.boxes
+		if otherGroup, ok := otherGroups[key]; ok {
+			for _, b := range otherGroup.boxes {
+				var remaining []box
+				for _, a := range boxes {
+					remaining = append(remaining, a.subtract(b)...)
+				}
+				boxes = remaining
+			}
+		}
+		for _, b := range boxes {
+			result.add(key, group.shape, b)
+		}
+	}
+	// Names held by groups of different shapes, see reconcile.
+	for _, pair := range sharingPairs(result.groups, otherGroups) {
+		g := result.groups[pair[0]]
+		g.remove(g.shared(otherGroups[pair[1]]))
+	}
+	result.normalizeBoxes()
+	return result
+}
+
+// SymmetricDifference returns a new NodeSet of the nodes in either ns or other, but not in both.
+func (ns *NodeSet) SymmetricDifference(other *NodeSet) *NodeSet {
+	return ns.Difference(other).Union(other.Difference(ns))
+}
+
+// Equal reports whether ns and other contain the same nodes.
+func (ns *NodeSet) Equal(other *NodeSet) bool {
+	return ns.IsSubset(other) && other.IsSubset(ns)
+}
+
+// IsSubset reports whether every node of ns is also in other.
+func (ns *NodeSet) IsSubset(other *NodeSet) bool {
+	return ns.Difference(other).IsEmpty()
+}
+
+// IsEmpty reports whether ns contains no nodes.
+func (ns *NodeSet) IsEmpty() bool {
+	return len(ns.groupMap()) == 0
+}
+
+// Len returns the number of nodes in ns, calculated without expanding the set. The
+// result saturates at math.MaxUint64 for sets too large to be counted.
+func (ns *NodeSet) Len() uint64 {
+	var total uint64
+	for _, group := range ns.groupMap() {
+		for _, b := range group.boxes {
+			n, ok := b.len()
+			if !ok {
+				return math.MaxUint64
+			}
+			var carry uint64
+			total, carry = bits.Add64(total, n, 0)
+			if carry != 0 {
+				return math.MaxUint64
+			}
+		}
+	}
+	return total
+}
+
+// String returns the folded form of ns, the same patterns returned by Fold seperated by comma.
+func (ns *NodeSet) String() string {
+	return strings.Join(ns.patterns(Options{}), ",")
+}
+
+// Fold returns the folded patterns of ns, in the same form as the Fold function.
+func (ns *NodeSet) Fold() []string {
+	return ns.patterns(Options{})
+}
+
+// FoldWithOptions returns the folded patterns of ns, in the same form as the
+// FoldWithOptions function.
+func (ns *NodeSet) FoldWithOptions(opts Options) []string {
+	return ns.patterns(opts)
+}
+
+// Expand calls iter with each node name of ns, in the same order as All.
+func (ns *NodeSet) Expand(iter func(s string) error) error {
+	if iter == nil {
+		return fmt.Errorf("iter function nil")
+	}
+	for name := range ns.All() {
+		if err := iter(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patterns returns the folded patterns of ns, folding arithmetic progressions into step
+// ranges, letters into alphabetic ranges, hexadecimal digits into hexadecimal ranges and
+// literal text into alternations as set by the fold options of opts.
+func (ns *NodeSet) patterns(opts Options) []string {
+	groups := ns.groupMap()
+	if opts.FoldHex {
+		groups = ns.hexGroups()
+	}
+	output := []string{}
+	for _, fb := range foldBoxes(groups) {
+		output = append(output, fb.format(opts.Autostep))
+	}
+	if opts.FoldLetters {
+		output = foldLetters(output, opts.Autostep)
+	}
+	if opts.FoldAlternations {
+		output = foldAlternations(output)
+	}
+	slices.SortFunc(output, func(x, y string) int {
+		return -(cmp.Compare(x, y))
+	})
+	return output
+}
+
+// hexGroups returns the groups of ns, where the names of groups holding runs of
+// hexadecimal digits are split by splitHexShape and folded again. Groups with digit
+// components not written in decimal are kept as is, and united with the groups of the
+// same shape split from names, which never hold the same names, see reconcile.
+func (ns *NodeSet) hexGroups() map[string]*shapeGroup {
+	groups := maps.Clone(ns.groupMap())
+	hex := &NodeSet{groups: make(map[string]*shapeGroup)}
+	for key, group := range ns.groupMap() {
+		if !group.shape.decimal() || !group.shape.hasHexRuns() {
+			continue
+		}
+		delete(groups, key)
+		for _, b := range group.boxes {
+			group.shape.names(b, func(name string) bool {
+				hex.add(splitHexShape(name))
+				return true
+			})
+		}
+	}
+	hex.normalizeBoxes()
+	for key, group := range hex.groups {
+		if existing, ok := groups[key]; ok {
+			group.boxes = mergeBoxes(slices.Concat(existing.boxes, group.boxes))
+		}
+		groups[key] = group
+	}
+	return groups
+}
+
+// groupMap returns the groups of ns, safe to call on a nil NodeSet.
+func (ns *NodeSet) groupMap() map[string]*shapeGroup {
+	if ns == nil {
+		return nil
+	}
+	return ns.groups
+}
+
+// clone returns a copy of ns that can be modified without affecting ns.
+func (ns *NodeSet) clone() *NodeSet {
+	result := &NodeSet{groups: make(map[string]*shapeGroup)}
+	for key, group := range ns.groupMap() {
+		result.groups[key] = &shapeGroup{shape: group.shape, boxes: slices.Clone(group.boxes)}
+	}
+	return result
+}
+
+// add appends box b of the given shape to ns. Boxes may overlap until normalize is called.
+func (ns *NodeSet) add(key string, s shape, b box) {
+	if group, ok := ns.groups[key]; ok {
+		group.boxes = append(group.boxes, b)
+		return
+	}
+	ns.groups[key] = &shapeGroup{shape: s, boxes: []box{b}}
+}
+
+// normalize folds the boxes of every group, and makes them disjoint so each node is
+// covered by exactly one box, see normalizeBoxes and reconcile.
+func (ns *NodeSet) normalize() {
+	ns.normalizeBoxes()
+	ns.reconcile(ns.groups)
+}
+
+// normalizeBoxes folds the boxes of every group, and makes them disjoint. Boxes are
+// folded before being made disjoint to keep the number of pairwise comparisons low.
+func (ns *NodeSet) normalizeBoxes() {
+	for key, group := range ns.groups {
+		var boxes []box
+		for _, b := range mergeBoxes(group.boxes) {
+			pieces := []box{b}
+			for _, existing := range boxes {
+				var remaining []box
+				for _, piece := range pieces {
+					remaining = append(remaining, piece.subtract(existing)...)
+				}
+				pieces = remaining
+			}
+			boxes = append(boxes, pieces...)
+		}
+		group.boxes = mergeBoxes(boxes)
+		if len(group.boxes) == 0 {
+			delete(ns.groups, key)
+		}
+	}
+}
+
+// reconcile removes the names of a group that another group of ns also holds, so each
+// node is held by a single group. Names split by splitShape always have the same shape,
+// but shapes with digit components not written in decimal, like the p[a-c] of a parsed
+// pattern, may hold names of other shapes, like pb. The names two such groups share
+// are removed from one of them, see overlap. Only groups with the keys of changed are
+// compared with the other groups of ns.
+func (ns *NodeSet) reconcile(changed map[string]*shapeGroup) {
+	for _, pair := range sharingPairs(changed, ns.groups) {
+		if _, ok := changed[pair[1]]; ok && pair[0] > pair[1] {
+			continue
+		}
+		g, h := ns.groups[pair[0]], ns.groups[pair[1]]
+		if g == nil || h == nil {
+			continue
+		}
+		owner, boxes := overlap(g, h)
+		key := pair[0]
+		if owner == h {
+			key = pair[1]
+		}
+		owner.remove(boxes)
+		if len(owner.boxes) == 0 {
+			delete(ns.groups, key)
+		}
+	}
+}
+
+// sharingPairs returns the keys of each pair of a group of a and a group of b that may
+// hold the same names despite having different shapes, which requires one of them to
+// have digit components not written in decimal.
+func sharingPairs(a, b map[string]*shapeGroup) [][2]string {
+	nonDecimal := func(groups map[string]*shapeGroup) []string {
+		var keys []string
+		for key, group := range groups {
+			if !group.shape.decimal() {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+		return keys
+	}
+	aOthers, bOthers := nonDecimal(a), nonDecimal(b)
+	if aOthers == nil && bOthers == nil {
+		return nil
+	}
+
+	var pairs [][2]string
+	bKeys := slices.Sorted(maps.Keys(b))
+	for _, x := range slices.Sorted(maps.Keys(a)) {
+		others := bKeys
+		if a[x].shape.decimal() {
+			others = bOthers
+		}
+		for _, y := range others {
+			if x != y && mayShare(a[x].shape, b[y].shape) {
+				pairs = append(pairs, [2]string{x, y})
+			}
+		}
+	}
+	return pairs
+}
+
+// len returns the number of names of g, saturating at math.MaxUint64.
+func (g *shapeGroup) len() uint64 {
+	var total uint64
+	for _, b := range g.boxes {
+		n, ok := b.len()
+		if !ok {
+			return math.MaxUint64
+		}
+		var carry uint64
+		if total, carry = bits.Add64(total, n, 0); carry != 0 {
+			return math.MaxUint64
+		}
+	}
+	return total
+}
+
+// contains reports whether g holds name.
+func (g *shapeGroup) contains(name string) bool {
+	values, ok := g.shape.parse(name)
+	if !ok {
+		return false
+	}
+	p := pointBox(values)
+	return slices.ContainsFunc(g.boxes, func(b box) bool {
+		_, ok := b.intersect(p)
+		return ok
+	})
+}
+
+// filter returns the names of g for which keep returns true.
+func (g *shapeGroup) filter(keep func(name string) bool) []string {
+	var names []string
+	for _, b := range g.boxes {
+		g.shape.names(b, func(name string) bool {
+			if keep(name) {
+				names = append(names, name)
+			}
+			return true
+		})
+	}
+	return names
+}
+
+// boxesOf returns boxes of g holding names, names g can't hold are ignored.
+func (g *shapeGroup) boxesOf(names []string) []box {
+	var boxes []box
+	for _, name := range names {
+		if values, ok := g.shape.parse(name); ok {
+			boxes = append(boxes, pointBox(values))
+		}
+	}
+	return mergeBoxes(boxes)
+}
+
+// overlap returns the names g and h share as boxes of one of them, either the group
+// they can be computed for from the values of both groups, see sharedBoxes, or the
+// smaller group, whose names are listed.
+func overlap(g, h *shapeGroup) (*shapeGroup, []box) {
+	if boxes, ok := sharedBoxes(g, h); ok {
+		return g, boxes
+	}
+	if boxes, ok := sharedBoxes(h, g); ok {
+		return h, boxes
+	}
+	if g.len() > h.len() {
+		g, h = h, g
+	}
+	return g, g.boxesOf(g.filter(h.contains))
+}
+
+// shared returns boxes of g holding the names g shares with h, see overlap.
+func (g *shapeGroup) shared(h *shapeGroup) []box {
+	owner, boxes := overlap(g, h)
+	if owner == g {
+		return boxes
+	}
+	var names []string
+	for _, b := range boxes {
+		owner.shape.names(b, func(name string) bool {
+			names = append(names, name)
+			return true
+		})
+	}
+	return g.boxesOf(names)
+}
+
+// sharedBoxes returns boxes of g holding the names g shares with h, computed from the
+// values of both groups, false when their shapes aren't aligned or their values can't
+// be compared, see shape.aligned and sharedValues.
+func sharedBoxes(g, h *shapeGroup) ([]box, bool) {
+	if !g.shape.aligned(h.shape) {
+		return nil, false
+	}
+	var boxes []box
+	for _, a := range g.boxes {
+		for _, b := range h.boxes {
+			c := make(box, len(a))
+			for i := range a {
+				values, ok := sharedValues(g.shape.formats[i], a[i], h.shape.formats[i], b[i])
+				if !ok {
+					return nil, false
+				}
+				c[i] = values
+			}
+			if !slices.ContainsFunc(c, func(values rangeSet) bool { return len(values) == 0 }) {
+				boxes = append(boxes, c)
+			}
+		}
+	}
+	return mergeBoxes(boxes), true
+}
+
+// remove removes the names of removed from g, names g doesn't hold are ignored.
+func (g *shapeGroup) remove(removed []box) {
+	boxes := g.boxes
+	for _, r := range removed {
+		var remaining []box
+		for _, b := range boxes {
+			remaining = append(remaining, b.subtract(r)...)
+		}
+		boxes = remaining
+	}
+	g.boxes = mergeBoxes(boxes)
+}
+
+// pointBox returns the box of a single name, holding the value of each of its digit
+// components.
+func pointBox(values []uint64) box {
+	b := make(box, len(values))
+	for i, v := range values {
+		b[i] = singleRange(v)
+	}
+	return b
+}
+
+// intersect returns the box of values in both b and o, false if they don't intersect.
+func (b box) intersect(o box) (box, bool) {
+	c := make(box, len(b))
+	for i := range b {
+		c[i] = b[i].intersect(o[i])
+		if len(c[i]) == 0 {
+			return nil, false
+		}
+	}
+	return c, true
+}
+
+// subtract returns disjoint boxes covering the values of b that aren't in o.
+func (b box) subtract(o box) []box {
+	if _, ok := b.intersect(o); !ok {
+		return []box{b}
+	}
+	var result []box
+	remaining := slices.Clone(b)
+	for i := range b {
+		if outside := remaining[i].subtract(o[i]); len(outside) > 0 {
+			result = append(result, remaining.with(i, outside))
+		}
+		remaining[i] = remaining[i].intersect(o[i])
+	}
+	return result
+}
+
+// len returns the number of names in b, false if the number overflows an uint64.
+func (b box) len() (uint64, bool) {
+	total := uint64(1)
+	for _, values := range b {
+		n, ok := values.len()
+		if !ok {
+			return 0, false
+		}
+		hi, lo := bits.Mul64(total, n)
+		if hi != 0 {
+			return 0, false
+		}
+		total = lo
+	}
+	return total, true
+}
@@ -0,0 +1,479 @@
+package nodeset
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "Single node",
+			pattern: "node1",
+			want:    "node1",
+		},
+		{
+			name:    "Range",
+			pattern: "node[1-4]",
+			want:    "node[1-4]",
+		},
+		{
+			name:    "Comma seperated nodes are folded",
+			pattern: "node1,node2,node3",
+			want:    "node[1-3]",
+		},
+		{
+			name:    "Overlapping patterns",
+			pattern: "node[1-4],node[3-6]",
+			want:    "node[1-6]",
+		},
+		{
+			name:    "Digits before a range are combined",
+			pattern: "x100[1-2]",
+			want:    "x[1001-1002]",
+		},
+		{
+			name:    "Adjacent ranges are combined",
+			pattern: "n[1-2][0-1]",
+			want:    "n[10-11,20-21]",
+		},
+		{
+			name:    "Large range followed by every digit",
+			pattern: "x[1-100000000][0-9]",
+			want:    "x[10-1000000009]",
+		},
+		{
+			name:    "Step range followed by a range",
+			pattern: "n[1-5/2][0-1]",
+			want:    "n[10-11,30-31,50-51]",
+		},
+		{
+			name:    "Zero followed by a range",
+			pattern: "n[0-1][8-9]",
+			want:    "n[08-09,18-19]",
+		},
+		{
+			name:    "Multiple ranges",
+			pattern: "rack[1-2]node[1-2],rack3node1",
+			want:    "rack[1-2]node[1-2],rack3node1",
+		},
+		{
+			name:    "Padded and unpadded values",
+			pattern: "node[01-10]",
+			want:    "node[01-10]",
+		},
+		{
+			name:    "Padded single values",
+			pattern: "node[01,05-07]",
+			want:    "node[01,05-07]",
+		},
+		{
+			name:    "Mixed widths of the same value",
+			pattern: "node[1,01,001]",
+			want:    "node[1,01,001]",
+		},
+		{
+			name:    "Letters are kept as ranges",
+			pattern: "oss[a-b],oss[1-2]",
+			want:    "oss[a-b],oss[1-2]",
+		},
+		{
+			name:    "Hexadecimal values are kept as ranges",
+			pattern: "port[0x0e-0x10]",
+			want:    "port[0x0e-0x10]",
+		},
+		{
+			name:    "Alternations",
+			pattern: "node[1-2]-{ib,eth},{node1,node3}-eth",
+			want:    "node[1-3]-eth,node[1-2]-ib",
+		},
+		{
+			name:    "Operators between alternations",
+			pattern: "n{1,2}!n{2,3}",
+			want:    "n1",
+		},
+		{
+			name:    "Letters next to digits",
+			pattern: "sw[1-2][a-b]!sw2b",
+			want:    "sw2a,sw1[a-b]",
+		},
+		{
+			name:    "Difference",
+			pattern: "node[1-100]!node[13,42]",
+			want:    "node[1-12,14-41,43-100]",
+		},
+		{
+			name:    "Intersection",
+			pattern: "node[1-10]&node[5-20]",
+			want:    "node[5-10]",
+		},
+		{
+			name:    "Symmetric difference",
+			pattern: "node[1-10]^node[5-20]",
+			want:    "node[1-4,11-20]",
+		},
+		{
+			name:    "Operators evaluated left to right",
+			pattern: "node[1-4],node[8-9]!node[2-8]",
+			want:    "node[1,9]",
+		},
+		{
+			name:    "Operators evaluated left to right, union last",
+			pattern: "node[1-4]!node[2-8],node[8-9]",
+			want:    "node[1,8-9]",
+		},
+		{
+			name:    "Operators within brackets are not operators",
+			pattern: "node[1,3]&node[3,5]",
+			want:    "node3",
+		},
+		{
+			name:    "Leading operator",
+			pattern: "!node1",
+			wantErr: true,
+		},
+		{
+			name:    "Trailing operator",
+			pattern: "node[1-2]&",
+			wantErr: true,
+		},
+		{
+			name:    "Consecutive operators",
+			pattern: "node[1-2]!^node1",
+			wantErr: true,
+		},
+		{
+			name:    "Empty pattern",
+			pattern: "",
+			wantErr: true,
+		},
+		{
+			name:    "Empty pattern between commas",
+			pattern: "node1,,node2",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid range",
+			pattern: "node[2-1]",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeSetOperations(t *testing.T) {
+	tests := []struct {
+		name                string
+		a, b                string
+		union               string
+		intersection        string
+		difference          string
+		symmetricDifference string
+	}{
+		{
+			name:                "Overlapping ranges",
+			a:                   "node[1-10]",
+			b:                   "node[5-15]",
+			union:               "node[1-15]",
+			intersection:        "node[5-10]",
+			difference:          "node[1-4]",
+			symmetricDifference: "node[1-4,11-15]",
+		},
+		{
+			name:                "Disjoint shapes",
+			a:                   "node[1-2]",
+			b:                   "gpu[1-2]",
+			union:               "node[1-2],gpu[1-2]",
+			intersection:        "",
+			difference:          "node[1-2]",
+			symmetricDifference: "node[1-2],gpu[1-2]",
+		},
+		{
+			name:                "Two dimensions",
+			a:                   "r[1-2]n[1-4]",
+			b:                   "r2n[3-6]",
+			union:               "r[1-2]n[1-4],r2n[5-6]",
+			intersection:        "r2n[3-4]",
+			difference:          "r2n[1-2],r1n[1-4]",
+			symmetricDifference: "r2n[1-2,5-6],r1n[1-4]",
+		},
+		{
+			name:                "No digits",
+			a:                   "login,admin",
+			b:                   "admin",
+			union:               "login,admin",
+			intersection:        "admin",
+			difference:          "login",
+			symmetricDifference: "login",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := Parse(tt.a)
+			if err != nil {
+				t.Fatalf("Parse(%s) error = %v", tt.a, err)
+			}
+			b, err := Parse(tt.b)
+			if err != nil {
+				t.Fatalf("Parse(%s) error = %v", tt.b, err)
+			}
+			if got := a.Union(b).String(); got != tt.union {
+				t.Errorf("Union() = %v, want %v", got, tt.union)
+			}
+			if got := a.Intersection(b).String(); got != tt.intersection {
+				t.Errorf("Intersection() = %v, want %v", got, tt.intersection)
+			}
+			if got := a.Difference(b).String(); got != tt.difference {
+				t.Errorf("Difference() = %v, want %v", got, tt.difference)
+			}
+			if got := a.SymmetricDifference(b).String(); got != tt.symmetricDifference {
+				t.Errorf("SymmetricDifference() = %v, want %v", got, tt.symmetricDifference)
+			}
+		})
+	}
+}
+
+func TestNodeSetSteps(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		len     uint64
+		str     string // String, without autostep.
+		want    string
+	}{
+		{
+			name:    "Step range",
+			pattern: "id[1-10000000000/2]",
+			len:     5000000000,
+			str:     "id[1-9999999999/2]",
+			want:    "id[1-9999999999/2]",
+		},
+		{
+			name:    "Overlapping step ranges",
+			pattern: "a[0-18446744073709551615/2,0-18446744073709551615/3]",
+			len:     12297829382473034411,
+			str:     "a[0-18446744073709551614/2,3-18446744073709551615/6]",
+			want:    "a[0-18446744073709551614/2,3-18446744073709551615/6]",
+		},
+		{
+			name:    "Value removed from a step range",
+			pattern: "n[1-9/2]!n5",
+			len:     4,
+			str:     "n[1,3,7,9]",
+			want:    "n[1-3/2,7-9/2]",
+		},
+		{
+			name:    "Step range within a product",
+			pattern: "r[1-3]n[1-99/2]",
+			len:     150,
+			str:     "r[1-3]n[1-99/2]",
+			want:    "r[1-3]n[1-99/2]",
+		},
+		{
+			name:    "Adjacent large ranges",
+			pattern: "n[1-9999][1-9999]&n[1-20]",
+			len:     9,
+			str:     "n[11-19]",
+			want:    "n[11-19]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns, err := Parse(tt.pattern)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := ns.Len(); got != tt.len {
+				t.Errorf("Len() = %d, want %d", got, tt.len)
+			}
+			if got := ns.String(); got != tt.str {
+				t.Errorf("String() = %v, want %v", got, tt.str)
+			}
+			if got := strings.Join(ns.FoldWithOptions(Options{Autostep: 2}), ","); got != tt.want {
+				t.Errorf("FoldWithOptions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeSetNotations(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		names   []string // Names of the set, when small enough to be listed.
+		len     uint64
+		want    string
+	}{
+		{
+			name:    "Hexadecimal range",
+			pattern: "p[0x0-0xffffffffff]",
+			len:     1099511627776,
+			want:    "p[0x0-0xffffffffff]",
+		},
+		{
+			name:    "Alphabetic range",
+			pattern: "p[a-zzzzzzz]",
+			len:     8353082582,
+			want:    "p[a-zzzzzzz]",
+		},
+		{
+			name:    "Name removed from an alphabetic range",
+			pattern: "p[a-zzzzzzz]!pzz",
+			len:     8353082581,
+			want:    "p[a-zy,aaa-zzzzzzz]",
+		},
+		{
+			name:    "Names already in a range",
+			pattern: "oss[a-c],ossb,oss1",
+			names:   []string{"ossa", "ossb", "ossc", "oss1"},
+			len:     4,
+			want:    "oss[a-c],oss1",
+		},
+		{
+			name:    "Intersection with names",
+			pattern: "p[0x00-0xff]&p0a,p0g,p1",
+			names:   []string{"p0a", "p0g", "p1"},
+			len:     3,
+			want:    "p1,p0g,p0a",
+		},
+		{
+			name:    "Adjacent letters spelled",
+			pattern: "n[a-b][a-b]!nab",
+			names:   []string{"naa", "nba", "nbb"},
+			len:     3,
+			want:    "nbb,nba,naa",
+		},
+		{
+			name:    "Decimal names of a hexadecimal range",
+			pattern: "x[0x0-0xffffff],x[0-9999999]",
+			len:     25777216,
+			want:    "x[1000000-9999999],x[0x0-0xffffff]",
+		},
+		{
+			name:    "Intersection of decimal and hexadecimal ranges",
+			pattern: "x[0x0-0xffffff]&x[0-9999999]",
+			len:     1000000,
+			want:    "x[0-999999]",
+		},
+		{
+			name:    "Decimal names removed from a hexadecimal range",
+			pattern: "x[0x00-0xff]!x[0-999]",
+			len:     166,
+			want:    "x[0x00-0x0f,0x1a-0x1f,0x2a-0x2f,0x3a-0x3f,0x4a-0x4f,0x5a-0x5f,0x6a-0x6f,0x7a-0x7f,0x8a-0x8f,0x9a-0xff]",
+		},
+		{
+			name:    "Padded decimal names of a hexadecimal range",
+			pattern: "n[000-999],n[0x0-0xfff]",
+			len:     4196,
+			want:    "n[0x0-0xfff],n[000-099]",
+		},
+		{
+			name:    "Decimal and hexadecimal components",
+			pattern: "n[1-20]-ib[0-9],n[0x1-0x14]-ib[0x0-0xf]",
+			len:     380,
+			want:    "n[15-20]-ib[0-9],n[0x1-0x14]-ib[0x0-0xf]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns, err := Parse(tt.pattern)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := ns.Len(); got != tt.len {
+				t.Errorf("Len() = %d, want %d", got, tt.len)
+			}
+			if got := ns.String(); got != tt.want {
+				t.Errorf("String() = %v, want %v", got, tt.want)
+			}
+			if tt.names != nil && !ns.Equal(NewNodeSet(tt.names...)) {
+				t.Errorf("Parse() = %v, want %v", ns, tt.names)
+			}
+		})
+	}
+}
+
+func TestNodeSetEqualAndIsSubset(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		equal    bool
+		isSubset bool
+	}{
+		{
+			name:     "Same nodes, different patterns",
+			a:        "r[1-2]n[1-2]",
+			b:        "r1n1,r1n2,r2n[1-2]",
+			equal:    true,
+			isSubset: true,
+		},
+		{
+			name:     "Subset",
+			a:        "node[2-3]",
+			b:        "node[1-4]",
+			isSubset: true,
+		},
+		{
+			name: "Superset",
+			a:    "node[1-4]",
+			b:    "node[2-3]",
+		},
+		{
+			name: "Padding differs",
+			a:    "node[1-2]",
+			b:    "node[01-02]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := Parse(tt.a)
+			if err != nil {
+				t.Fatalf("Parse(%s) error = %v", tt.a, err)
+			}
+			b, err := Parse(tt.b)
+			if err != nil {
+				t.Fatalf("Parse(%s) error = %v", tt.b, err)
+			}
+			if got := a.Equal(b); got != tt.equal {
+				t.Errorf("Equal() = %v, want %v", got, tt.equal)
+			}
+			if got := a.IsSubset(b); got != tt.isSubset {
+				t.Errorf("IsSubset() = %v, want %v", got, tt.isSubset)
+			}
+		})
+	}
+}
+
+func TestNodeSetZeroValue(t *testing.T) {
+	var empty NodeSet
+	ns := NewNodeSet("node1", "node2")
+
+	if !empty.IsEmpty() {
+		t.Errorf("IsEmpty() = false, want true")
+	}
+	if got := empty.Union(ns).String(); got != "node[1-2]" {
+		t.Errorf("Union() = %v, want node[1-2]", got)
+	}
+	if got := ns.Difference(&empty).String(); got != "node[1-2]" {
+		t.Errorf("Difference() = %v, want node[1-2]", got)
+	}
+	if !empty.IsSubset(ns) {
+		t.Errorf("IsSubset() = false, want true")
+	}
+}
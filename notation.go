@@ -0,0 +1,282 @@
+package nodeset
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Notation is the way the values of a Range are written in node names. The values of a
+// bracket can't be written in notations sharing characters, like the 10 and 0x10 of
+// [10,0x10], as they could result in the same names.
+type Notation int
+
+const (
+	// Decimal values are written in base 10, optionally zero padded, like 7 or 007.
+	Decimal Notation = iota
+	// LowerAlpha values are written with lower case letters, counting from a to z, then
+	// from aa to az, ba to bz and so on, like the columns of a spreadsheet.
+	LowerAlpha
+	// UpperAlpha values are written like LowerAlpha values, with upper case letters.
+	UpperAlpha
+	// LowerHex values are written in base 16 with lower case letters, optionally zero
+	// padded, like 3f or 0a1f. Within brackets they are marked with a 0x prefix that
+	// isn't part of the names, like port[0x00-0x3f].
+	LowerHex
+	// UpperHex values are written like LowerHex values, with upper case letters.
+	UpperHex
+)
+
+// hex reports whether n is LowerHex or UpperHex.
+func (n Notation) hex() bool {
+	return n == LowerHex || n == UpperHex
+}
+
+// alphabet returns the characters values written in n are made of.
+func (n Notation) alphabet() string {
+	switch n {
+	case LowerAlpha:
+		return "abcdefghijklmnopqrstuvwxyz"
+	case UpperAlpha:
+		return "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	case LowerHex:
+		return "0123456789abcdef"
+	case UpperHex:
+		return "0123456789ABCDEF"
+	default:
+		return "0123456789"
+	}
+}
+
+// base returns the number of distinct digits of n.
+func (n Notation) base() uint64 {
+	switch n {
+	case LowerAlpha, UpperAlpha:
+		return 26
+	case LowerHex, UpperHex:
+		return 16
+	default:
+		return 10
+	}
+}
+
+// parse returns the value of digits written in n, without any radix marker, false if
+// digits aren't written in n.
+func (n Notation) parse(digits string) (uint64, bool) {
+	switch n {
+	case LowerAlpha:
+		return parseLetters(digits, 'a')
+	case UpperAlpha:
+		return parseLetters(digits, 'A')
+	case LowerHex, UpperHex:
+		if n == LowerHex && strings.ContainsAny(digits, "ABCDEF") || n == UpperHex && strings.ContainsAny(digits, "abcdef") {
+			return 0, false
+		}
+		v, err := strconv.ParseUint(digits, 16, 64)
+		return v, err == nil
+	default:
+		v, err := strconv.ParseUint(digits, 10, 64)
+		return v, err == nil
+	}
+}
+
+// valueFormat is how the values of a range are written, values of different formats
+// never being equal even when they are the same number.
+type valueFormat struct {
+	notation Notation
+	padding  int
+}
+
+// valueFormat returns the format of the values of r.
+func (r Range) valueFormat() valueFormat {
+	return valueFormat{notation: r.Notation, padding: r.Padding}
+}
+
+// format returns v written in f.
+func (f valueFormat) format(v uint64) string {
+	switch f.notation {
+	case LowerAlpha:
+		return formatLetters(v, 'a')
+	case UpperAlpha:
+		return formatLetters(v, 'A')
+	case LowerHex:
+		return zeroPad(strconv.FormatUint(v, 16), f.padding)
+	case UpperHex:
+		return zeroPad(strings.ToUpper(strconv.FormatUint(v, 16)), f.padding)
+	default:
+		return formatPadded(v, f.padding)
+	}
+}
+
+// marker returns the radix marker preceding values of f within brackets.
+func (f valueFormat) marker() string {
+	if f.notation.hex() {
+		return "0x"
+	}
+	return ""
+}
+
+// parseValue parses a single value of a bracket, either digits, letters of a single
+// case, or hexadecimal digits following a 0x radix marker, returning the value, its
+// notation and its digits without the radix marker, false if s is none of those.
+func parseValue(s string) (uint64, Notation, string, bool) {
+	if digits, ok := strings.CutPrefix(s, "0x"); ok {
+		n := hexNotation(digits)
+		v, ok := n.parse(digits)
+		return v, n, digits, ok
+	}
+	for _, n := range []Notation{Decimal, LowerAlpha, UpperAlpha} {
+		if v, ok := n.parse(s); ok {
+			return v, n, s, true
+		}
+	}
+	return 0, Decimal, s, false
+}
+
+// hexNotation returns UpperHex if the hexadecimal digits hold an upper case letter,
+// LowerHex otherwise.
+func hexNotation(digits string) Notation {
+	if strings.ContainsAny(digits, "ABCDEF") {
+		return UpperHex
+	}
+	return LowerHex
+}
+
+// parseLetters returns the value of the letters of s in bijective base 26, starting
+// with first standing for 0, false if s holds anything else or overflows an uint64.
+func parseLetters(s string, first byte) (uint64, bool) {
+	if s == "" {
+		return 0, false
+	}
+	var v uint64
+	for i := 0; i < len(s); i++ {
+		if s[i] < first || s[i] > first+25 {
+			return 0, false
+		}
+		digit := uint64(s[i]-first) + 1
+		if v > (math.MaxUint64-digit)/26 {
+			return 0, false
+		}
+		v = v*26 + digit
+	}
+	return v - 1, true
+}
+
+// formatLetters returns v written in bijective base 26 with first standing for 0,
+// the reverse of parseLetters.
+func formatLetters(v uint64, first byte) string {
+	var buf [14]byte
+	i := len(buf)
+	for n := v + 1; n > 0; n = (n - 1) / 26 {
+		i--
+		buf[i] = first + byte((n-1)%26)
+	}
+	return string(buf[i:])
+}
+
+// isLetter reports whether c is an ASCII letter.
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// isDigit reports whether c is an ASCII digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isAlnum reports whether c is an ASCII letter or digit.
+func isAlnum(c byte) bool {
+	return isDigit(c) || isLetter(c)
+}
+
+// sharedValues returns the values of ra written in fa that are written the same as a
+// value of rb written in fb. It returns false unless fa and fb only differ by zero
+// padding, or fa is decimal and fb hexadecimal, and when rb holds a step range of
+// hexadecimal values. The decimal digits written the same in both, for a given number
+// of digits and range of hexadecimal values, are a single range of decimal values, as
+// decimal digits sort the same way in both notations.
+func sharedValues(fa valueFormat, ra rangeSet, fb valueFormat, rb rangeSet) (rangeSet, bool) {
+	switch {
+	case fa == fb || fa.notation == fb.notation && (fa.notation == LowerAlpha || fa.notation == UpperAlpha):
+		return ra.intersect(rb), true
+	case fa.notation != fb.notation && (fa.notation != Decimal || !fb.notation.hex()):
+		return nil, false
+	}
+
+	var shared []interval
+	base := fa.notation.base()
+	for length := 1; length <= 16 || !fb.notation.hex(); length++ {
+		first, ok := pow(base, length-1)
+		if !ok {
+			break
+		}
+		if length < fa.padding || length < fb.padding {
+			continue
+		}
+		if length == max(fa.padding, 1) && length == max(fb.padding, 1) {
+			first = 0
+		}
+		last := uint64(math.MaxUint64)
+		if n, ok := pow(base, length); ok {
+			last = n - 1
+		}
+
+		if fa.notation == fb.notation {
+			shared = append(shared, rangeSet{{lo: first, hi: last}}.intersect(rb)...)
+			continue
+		}
+		for _, iv := range rb {
+			if iv.step != 0 && iv.lo != iv.hi {
+				return nil, false
+			}
+			lo, ok := decimalCeil(iv.lo, length)
+			hi := decimalFloor(iv.hi, length)
+			lo, hi = max(lo, first), min(hi, last)
+			if ok && lo <= hi {
+				shared = append(shared, interval{lo: lo, hi: hi})
+			}
+		}
+	}
+	return rangeSetOf(shared).intersect(ra), true
+}
+
+// decimalCeil returns the lowest number of at most length decimal digits whose digits,
+// zero padded to length, are at least v when read in hexadecimal, false if there's none.
+// length is at most 16.
+func decimalCeil(v uint64, length int) (uint64, bool) {
+	digits := strconv.FormatUint(v, 16)
+	if len(digits) > length {
+		return 0, false
+	}
+	digits = zeroPad(digits, length)
+	i := strings.IndexFunc(digits, func(r rune) bool { return !isDigit(byte(r)) })
+	if i < 0 {
+		n, _ := strconv.ParseUint(digits, 10, 64)
+		return n, true
+	}
+	// Digits from the first letter on can't be kept, the digits before it are
+	// incremented and followed by zeros.
+	prefix, _ := strconv.ParseUint("0"+digits[:i], 10, 64)
+	bound, _ := pow10(i)
+	if prefix+1 >= bound {
+		return 0, false
+	}
+	scale, _ := pow10(length - i)
+	return (prefix + 1) * scale, true
+}
+
+// decimalFloor returns the highest number of at most length decimal digits whose
+// digits, zero padded to length, are at most v when read in hexadecimal. length is at
+// most 16.
+func decimalFloor(v uint64, length int) uint64 {
+	digits := strconv.FormatUint(v, 16)
+	if len(digits) > length {
+		digits = strings.Repeat("9", length)
+	}
+	digits = zeroPad(digits, length)
+	if i := strings.IndexFunc(digits, func(r rune) bool { return !isDigit(byte(r)) }); i >= 0 {
+		digits = digits[:i] + strings.Repeat("9", length-i)
+	}
+	n, _ := strconv.ParseUint(digits, 10, 64)
+	return n
+}
@@ -0,0 +1,86 @@
+package nodeset
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTooLarge is returned when a pattern covers more nodes than allowed by Options.MaxNodes.
+var ErrTooLarge = errors.New("too many nodes")
+
+// Options configures how patterns are parsed and limits the work done for patterns
+// from untrusted sources.
+type Options struct {
+	// MaxNodes is the maximum number of nodes a pattern may cover, 0 for no limit.
+	MaxNodes uint64
+	// Resolver resolves group references like @compute, nil to reject them.
+	Resolver GroupResolver
+	// Autostep is the minimum number of values of an arithmetic progression folded into
+	// a step range like node[1-99/2] by FoldWithOptions, 0 to never fold step ranges.
+	// Step ranges of parsed patterns are kept as step ranges either way.
+	Autostep int
+	// FoldLetters folds names only differing by their last letter into alphabetic ranges
+	// like oss[a-c] in FoldWithOptions, rather than returning a pattern per letter.
+	FoldLetters bool
+	// FoldHex folds runs of hexadecimal digits, like the 0a1f of bmc-0a1f, into ranges
+	// like bmc-[0x0a1f-0x0a2f] in FoldWithOptions. A run must start with a decimal digit,
+	// hold a letter of a single case, and be surrounded by characters other than ASCII
+	// letters and digits, or end the name, so decimal digits and words like the db1 of
+	// node-db1 or the cafe of gpu-cafe aren't taken as hexadecimal.
+	FoldHex bool
+	// FoldAlternations factors patterns only differing by some literal text into an
+	// alternation, like node[1-4]-{eth,ib} for node[1-4]-eth and node[1-4]-ib, in
+	// FoldWithOptions.
+	FoldAlternations bool
+}
+
+// check returns an error wrapping ErrTooLarge when p covers more nodes than allowed by o.
+// The nodes are only counted up to o.MaxNodes+1, so checking doesn't take longer for
+// larger patterns.
+func (o Options) check(p *Pattern) error {
+	if o.MaxNodes == 0 {
+		return nil
+	}
+	n, err := countSegments(p.pattern, p.segments, o.MaxNodes)
+	if err != nil {
+		return fmt.Errorf("pattern %s, contains more nodes than can be counted: %w", p.pattern, ErrTooLarge)
+	}
+	if n > o.MaxNodes {
+		return fmt.Errorf("pattern %s, contains more than the maximum of %d nodes: %w", p.pattern, o.MaxNodes, ErrTooLarge)
+	}
+	return nil
+}
+
+// ExpandWithOptions is like Expand, but first checks the size of the pattern against
+// opts. The size is calculated from the ranges of the pattern, so an error wrapping
+// ErrTooLarge is returned before iter is called for any node.
+func ExpandWithOptions(pattern string, opts Options, iter func(s string) error) error {
+	if pattern == "" {
+		return fmt.Errorf("empty pattern")
+	}
+	if iter == nil {
+		return fmt.Errorf("iter function nil")
+	}
+	p, err := Compile(pattern)
+	if err != nil {
+		return err
+	}
+	return p.ExpandWithOptions(opts, iter)
+}
+
+// ExpandWithOptions is like Expand, but first checks the size of the pattern against opts.
+func (p *Pattern) ExpandWithOptions(opts Options, iter func(s string) error) error {
+	if err := opts.check(p); err != nil {
+		return err
+	}
+	return p.Expand(iter)
+}
+
+// ParseWithOptions is like Parse, but resolves group references through opts.Resolver,
+// and checks the size of each operand, and of the result after each operator, against
+// opts.MaxNodes. An error wrapping ErrTooLarge is returned before an operand larger
+// than allowed is parsed.
+func ParseWithOptions(pattern string, opts Options) (*NodeSet, error) {
+	p := &parser{opts: opts}
+	return p.parse(pattern)
+}
@@ -0,0 +1,145 @@
+package nodeset
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExpandWithOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		pattern      string
+		opts         Options
+		want         []string
+		wantErr      bool
+		wantTooLarge bool
+	}{
+		{
+			name:    "No limit",
+			pattern: "node[1-3]",
+			want:    []string{"node1", "node2", "node3"},
+		},
+		{
+			name:    "Within limit",
+			pattern: "node[1-3]",
+			opts:    Options{MaxNodes: 3},
+			want:    []string{"node1", "node2", "node3"},
+		},
+		{
+			name:         "Over limit",
+			pattern:      "node[1-4]",
+			opts:         Options{MaxNodes: 3},
+			want:         []string{},
+			wantErr:      true,
+			wantTooLarge: true,
+		},
+		{
+			name:         "Product over limit",
+			pattern:      "a[1-999999999]b[1-999999999]",
+			opts:         Options{MaxNodes: 100000},
+			want:         []string{},
+			wantErr:      true,
+			wantTooLarge: true,
+		},
+		{
+			name:         "Product not countable",
+			pattern:      "a[0-18446744073709551615]b[1-2]",
+			opts:         Options{MaxNodes: 100000},
+			want:         []string{},
+			wantErr:      true,
+			wantTooLarge: true,
+		},
+		{
+			name:         "Alternation over limit",
+			pattern:      "{a[1-1000000000][0-9],b}",
+			opts:         Options{MaxNodes: 1000},
+			want:         []string{},
+			wantErr:      true,
+			wantTooLarge: true,
+		},
+		{
+			name:    "Names produced twice within limit",
+			pattern: "n[1,11][1,11]",
+			opts:    Options{MaxNodes: 3},
+			want:    []string{"n11", "n111", "n111", "n1111"},
+		},
+		{
+			name:         "Names produced twice over limit",
+			pattern:      "p[a-zz][a-zz][a-zz][a-zz][a-zz][a-zz]",
+			opts:         Options{MaxNodes: 1000},
+			want:         []string{},
+			wantErr:      true,
+			wantTooLarge: true,
+		},
+		{
+			name:    "Invalid pattern",
+			pattern: "node[1-",
+			opts:    Options{MaxNodes: 3},
+			want:    []string{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			err := ExpandWithOptions(tt.pattern, tt.opts, func(s string) error {
+				got = append(got, s)
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ExpandWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if errors.Is(err, ErrTooLarge) != tt.wantTooLarge {
+				t.Errorf("ExpandWithOptions() error = %v, wantTooLarge %v", err, tt.wantTooLarge)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandWithOptions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWithOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		pattern      string
+		opts         Options
+		want         string
+		wantTooLarge bool
+	}{
+		{
+			name:    "Within limit",
+			pattern: "node[1-10]!node[2-9]",
+			opts:    Options{MaxNodes: 10},
+			want:    "node[1,10]",
+		},
+		{
+			name:         "Operand over limit",
+			pattern:      "node[1-2]!node[1-999999999/2]",
+			opts:         Options{MaxNodes: 10},
+			wantTooLarge: true,
+		},
+		{
+			name:         "Union over limit",
+			pattern:      "node[1-6],gpu[1-6]",
+			opts:         Options{MaxNodes: 10},
+			wantTooLarge: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWithOptions(tt.pattern, tt.opts)
+			if errors.Is(err, ErrTooLarge) != tt.wantTooLarge {
+				t.Fatalf("ParseWithOptions() error = %v, wantTooLarge %v", err, tt.wantTooLarge)
+			}
+			if err != nil {
+				return
+			}
+			if got.String() != tt.want {
+				t.Errorf("ParseWithOptions() = %v, want %v", got.String(), tt.want)
+			}
+		})
+	}
+}
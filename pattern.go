@@ -0,0 +1,118 @@
+package nodeset
+
+import (
+	"fmt"
+	"iter"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// Range is a range of values from a bracket expression of a node pattern. The range
+// [01-10/2] has a Start of 1, an End of 10, a Step of 2 and a Padding of 2, while a
+// single value like [5] has the same Start and End and a Step of 1. Letters are values
+// too, the range [a-c] has a Start of 0, an End of 2 and a Notation of LowerAlpha.
+type Range struct {
+	Start, End, Step uint64
+	Padding          int      // Length values are zero padded to, 0 when values aren't padded.
+	Notation         Notation // How values are written, Decimal for digits.
+}
+
+// Pattern is a compiled node pattern, parsed once so it can be expanded, counted and
+// matched repeatedly without parsing the pattern again. A Pattern is immutable and safe
+// for concurrent use by multiple goroutines.
+type Pattern struct {
+	pattern  string
+	segments []segment
+}
+
+// Compile parses a node pattern like 'rack[1-2]node[01-10]', accepting the same syntax
+// as Expand, and returns a Pattern that can be used to expand, count and match it.
+func Compile(pattern string) (*Pattern, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+	segments, err := splitPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &Pattern{pattern: pattern, segments: segments}, nil
+}
+
+// MustCompile is like Compile but panics if the pattern cannot be parsed. It simplifies
+// initialization of global variables holding compiled patterns.
+func MustCompile(pattern string) *Pattern {
+	p, err := Compile(pattern)
+	if err != nil {
+		panic(`nodeset: Compile(` + strconv.Quote(pattern) + `): ` + err.Error())
+	}
+	return p
+}
+
+// String returns the source text used to compile the pattern.
+func (p *Pattern) String() string {
+	return p.pattern
+}
+
+// Ranges returns the ranges of each bracket expression of the pattern, including those
+// within alternations, in the order the brackets appear. The returned slices are copies
+// and can be modified by the caller.
+func (p *Pattern) Ranges() [][]Range {
+	return segmentRanges(p.segments)
+}
+
+// segmentRanges returns the ranges of each bracket segment of segments, and of the
+// segments of their alternatives.
+func segmentRanges(segments []segment) [][]Range {
+	var ranges [][]Range
+	for _, seg := range segments {
+		if seg.ranges != nil {
+			ranges = append(ranges, slices.Clone(seg.ranges))
+		}
+		for _, alternative := range seg.alternatives {
+			ranges = append(ranges, segmentRanges(alternative)...)
+		}
+	}
+	return ranges
+}
+
+// Expand calls iter per node name of the pattern, in the same order as the Expand function.
+func (p *Pattern) Expand(iter func(s string) error) error {
+	if iter == nil {
+		return fmt.Errorf("iter function nil")
+	}
+	for name := range p.All() {
+		if err := iter(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// All returns an iterator over the node names of the pattern, in the same order as the
+// Expand function.
+func (p *Pattern) All() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		c := newProductCursor(p.segments)
+		for name, ok := c.next(); ok; name, ok = c.next() {
+			if !yield(name) {
+				return
+			}
+		}
+	}
+}
+
+// Count returns the number of node names of the pattern, calculated from its ranges
+// without expanding it. Values that appear in more than one range of a bracket, names
+// produced by more than one alternative of an alternation, and names produced by more
+// than one combination of the values of adjacent brackets, like n111 of n[1,11][1,11],
+// are only counted once, so Count matches the Len of the NodeSet of the pattern.
+func (p *Pattern) Count() (uint64, error) {
+	return countSegments(p.pattern, p.segments, math.MaxUint64)
+}
+
+// Contains reports whether name is one of the node names of the pattern, without
+// expanding it.
+func (p *Pattern) Contains(name string) bool {
+	return matchSegments(p.segments, name)
+}
@@ -0,0 +1,106 @@
+package nodeset
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    [][]Range
+		wantErr bool
+	}{
+		{
+			name:    "No range",
+			pattern: "node1",
+			want:    nil,
+		},
+		{
+			name:    "Union and step ranges",
+			pattern: "rack[1-2]node[01-10/2,15]",
+			want: [][]Range{
+				{{Start: 1, End: 2, Step: 1}},
+				{{Start: 1, End: 10, Step: 2, Padding: 2}, {Start: 15, End: 15, Step: 1}},
+			},
+		},
+		{
+			name:    "Empty pattern",
+			pattern: "",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid range",
+			pattern: "node[2-1]",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Compile(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Compile() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if got.String() != tt.pattern {
+				t.Errorf("String() = %v, want %v", got.String(), tt.pattern)
+			}
+			if ranges := got.Ranges(); !reflect.DeepEqual(ranges, tt.want) {
+				t.Errorf("Ranges() = %v, want %v", ranges, tt.want)
+			}
+		})
+	}
+}
+
+func TestMustCompile(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("MustCompile() did not panic on an invalid pattern")
+		}
+	}()
+	MustCompile("node[1-")
+}
+
+func TestPatternRangesCopy(t *testing.T) {
+	p := MustCompile("node[1-4]")
+	p.Ranges()[0][0].End = 100
+
+	if n, _ := p.Count(); n != 4 {
+		t.Errorf("Count() = %v after modifying Ranges(), want 4", n)
+	}
+}
+
+func TestPatternConcurrentUse(t *testing.T) {
+	p := MustCompile("rack[1-4]node[01-16/3]")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var names []string
+			err := p.Expand(func(s string) error {
+				names = append(names, s)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Expand() error = %v", err)
+			}
+			count, err := p.Count()
+			if err != nil || count != uint64(len(names)) {
+				t.Errorf("Count() = %v, %v, want %v", count, err, len(names))
+			}
+			for _, name := range names {
+				if !p.Contains(name) {
+					t.Errorf("Contains(%s) = false, want true", name)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
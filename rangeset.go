@@ -0,0 +1,661 @@
+package nodeset
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"math"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// interval is an arithmetic progression of unsigned integers from lo to hi, step values
+// apart. A step of 0 stands for every value from lo to hi, and is also the step of an
+// interval holding a single value. hi is always one of the values of the interval.
+type interval struct {
+	lo, hi, step uint64
+}
+
+// newInterval returns the interval of the values from lo up to hi, step values apart,
+// where a step of 0 or 1 stands for every value. hi is lowered to the last value reached
+// by the step.
+func newInterval(lo, hi, step uint64) interval {
+	if step > 1 {
+		hi = lo + (hi-lo)/step*step
+	}
+	if step <= 1 || hi == lo {
+		return interval{lo: lo, hi: hi}
+	}
+	return interval{lo: lo, hi: hi, step: step}
+}
+
+// stride returns the distance between consecutive values of iv.
+func (iv interval) stride() uint64 {
+	return max(iv.step, 1)
+}
+
+// count returns the number of values of iv, false if the number overflows an uint64.
+func (iv interval) count() (uint64, bool) {
+	n := (iv.hi - iv.lo) / iv.stride()
+	if n == math.MaxUint64 {
+		return 0, false
+	}
+	return n + 1, true
+}
+
+// values returns an iterator over the values of iv in ascending order.
+func (iv interval) values() iter.Seq[uint64] {
+	return func(yield func(uint64) bool) {
+		for v := iv.lo; ; v += iv.stride() {
+			if !yield(v) || v == iv.hi {
+				return
+			}
+		}
+	}
+}
+
+// intersect returns the values of both iv and o, false if they have none in common. The
+// first common value is found with the Chinese remainder theorem, the following ones are
+// the least common multiple of both steps apart.
+func (iv interval) intersect(o interval) (interval, bool) {
+	lo, hi := max(iv.lo, o.lo), min(iv.hi, o.hi)
+	if lo > hi {
+		return interval{}, false
+	}
+	switch {
+	case iv.step == 0 && o.step == 0:
+		return interval{lo: lo, hi: hi}, true
+	case iv.step == 0 || o.step == 0, iv.step == o.step:
+		// Values of the interval with a step from lo are common when the other interval
+		// has no step, or the same step and values.
+		p := o
+		if o.step == 0 {
+			p = iv
+		} else if iv.step == o.step && iv.lo%iv.step != o.lo%o.step {
+			return interval{}, false
+		}
+		first := p.lo
+		if lo > p.lo {
+			k := (lo - p.lo) / p.step
+			if (lo-p.lo)%p.step != 0 {
+				k++
+			}
+			if k > (p.hi-p.lo)/p.step {
+				return interval{}, false
+			}
+			first = p.lo + k*p.step
+		}
+		if first > hi {
+			return interval{}, false
+		}
+		return newInterval(first, hi, p.step), true
+	}
+
+	a, b := iv.stride(), o.stride()
+	g := gcd(a, b)
+	// iv.lo + a*t is a common value for t = diff/g * (a/g)^-1 modulo b/g, diff being the
+	// signed distance from iv.lo to o.lo.
+	diff, negative := o.lo-iv.lo, o.lo < iv.lo
+	if negative {
+		diff = iv.lo - o.lo
+	}
+	if diff%g != 0 {
+		return interval{}, false
+	}
+	m := b / g
+	t := uint64(0)
+	if m > 1 {
+		t = mulMod((diff/g)%m, modInverse((a/g)%m, m), m)
+		if negative && t != 0 {
+			t = m - t
+		}
+	}
+	hi64, at := bits.Mul64(a, t)
+	x, carry := bits.Add64(iv.lo, at, 0)
+	if hi64 != 0 || carry != 0 {
+		return interval{}, false
+	}
+	// Common values are lcm apart, only x is common when lcm overflows an uint64.
+	lcmHi, lcm := bits.Mul64(a, m)
+	if x < lo {
+		if lcmHi != 0 {
+			return interval{}, false
+		}
+		k := (lo - x) / lcm
+		if (lo-x)%lcm != 0 {
+			k++
+		}
+		kHi, step := bits.Mul64(k, lcm)
+		if x, carry = bits.Add64(x, step, 0); kHi != 0 || carry != 0 {
+			return interval{}, false
+		}
+	}
+	if x > hi {
+		return interval{}, false
+	}
+	if lcmHi != 0 || (hi-x)/lcm == 0 {
+		return interval{lo: x, hi: x}, true
+	}
+	return interval{lo: x, hi: x + (hi-x)/lcm*lcm, step: lcm}, true
+}
+
+// gcd returns the greatest common divisor of a and b.
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// mulMod returns x*y modulo m, without overflowing.
+func mulMod(x, y, m uint64) uint64 {
+	hi, lo := bits.Mul64(x, y)
+	_, rem := bits.Div64(hi%m, lo, m)
+	return rem
+}
+
+// subMod returns x-y modulo m, for x and y less than m.
+func subMod(x, y, m uint64) uint64 {
+	if x >= y {
+		return x - y
+	}
+	return x + (m - y)
+}
+
+// modInverse returns the inverse of x modulo m, which must be coprime and greater than 1.
+// The coefficients of the extended Euclidean algorithm are kept modulo m, so they fit
+// an uint64.
+func modInverse(x, m uint64) uint64 {
+	r0, r1 := m, x
+	t0, t1 := uint64(0), uint64(1)
+	for r1 != 0 {
+		q := r0 / r1
+		r0, r1 = r1, r0-q*r1
+		t0, t1 = t1, subMod(t0, mulMod(q%m, t1, m), m)
+	}
+	return t0
+}
+
+// subtract returns intervals holding the values of iv not present in o.
+func (iv interval) subtract(o interval) []interval {
+	c, ok := iv.intersect(o)
+	if !ok {
+		return []interval{iv}
+	}
+	s := iv.stride()
+	var result []interval
+	before, after := c.lo > iv.lo, c.hi < iv.hi
+	if c.step != 0 {
+		// Common values are k values of iv apart, leaving k-1 progressions of the values
+		// in between, or a run of k-1 values between each pair of common values,
+		// whichever takes fewer intervals. Less than k values before or after the common
+		// values continue the progressions.
+		k := c.step / s
+		n, _ := c.count()
+		if k-1 < n-1 {
+			extendDown := before && c.lo-iv.lo < c.step
+			extendUp := after && iv.hi-c.hi < c.step
+			for j := uint64(1); j < k; j++ {
+				lo, hi := c.lo+j*s, c.hi-(k-j)*s
+				if extendDown && c.lo-iv.lo >= (k-j)*s {
+					lo = c.lo - (k-j)*s
+				}
+				if extendUp && iv.hi-c.hi >= j*s {
+					hi = c.hi + j*s
+				}
+				result = append(result, newInterval(lo, hi, c.step))
+			}
+			before, after = before && !extendDown, after && !extendUp
+		} else {
+			for v := c.lo; v < c.hi; v += c.step {
+				result = append(result, newInterval(v+s, v+c.step-s, iv.step))
+			}
+		}
+	}
+	if before {
+		result = append(result, newInterval(iv.lo, c.lo-s, iv.step))
+	}
+	if after {
+		result = append(result, newInterval(c.hi+s, iv.hi, iv.step))
+	}
+	return result
+}
+
+// rangeSet is a set of unsigned integers stored as intervals sorted by their first value,
+// holding distinct values. Intervals without a step don't overlap and aren't adjacent,
+// while intervals with a step may span other intervals, like 1-9/2 and 4-6 holding 1, 3,
+// 4-6, 7 and 9. The zero value is an empty set.
+type rangeSet []interval
+
+// singleRange returns a rangeSet containing only v.
+func singleRange(v uint64) rangeSet {
+	return rangeSet{{lo: v, hi: v}}
+}
+
+// stepped reports whether r holds an interval with a step.
+func (r rangeSet) stepped() bool {
+	return slices.ContainsFunc(r, func(iv interval) bool {
+		return iv.step != 0
+	})
+}
+
+// union returns a new rangeSet containing the values of both r and o.
+func (r rangeSet) union(o rangeSet) rangeSet {
+	if r.stepped() || o.stepped() {
+		return rangeSetOf(slices.Concat(r, o))
+	}
+	result := make(rangeSet, 0, len(r)+len(o))
+	i, j := 0, 0
+	for i < len(r) || j < len(o) {
+		var next interval
+		if j == len(o) || (i < len(r) && r[i].lo <= o[j].lo) {
+			next = r[i]
+			i++
+		} else {
+			next = o[j]
+			j++
+		}
+
+		// Merge with the previous interval when overlapping or adjacent.
+		if n := len(result); n > 0 && (result[n-1].hi == math.MaxUint64 || next.lo <= result[n-1].hi+1) {
+			if next.hi > result[n-1].hi {
+				result[n-1].hi = next.hi
+			}
+			continue
+		}
+		result = append(result, next)
+	}
+	return result
+}
+
+// equal reports whether r and o contain the same values.
+func (r rangeSet) equal(o rangeSet) bool {
+	if len(r) != len(o) {
+		return false
+	}
+	for i := range r {
+		if r[i] != o[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// key returns a compact string uniquely identifying the values of r.
+func (r rangeSet) key() string {
+	var sb strings.Builder
+	for i, iv := range r {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatUint(iv.lo, 10))
+		if iv.hi != iv.lo {
+			sb.WriteByte('-')
+			sb.WriteString(strconv.FormatUint(iv.hi, 10))
+		}
+		if iv.step != 0 {
+			sb.WriteByte('/')
+			sb.WriteString(strconv.FormatUint(iv.step, 10))
+		}
+	}
+	return sb.String()
+}
+
+// format returns the range elements of r written in f, and whether the elements need
+// to be enclosed in brackets when written as a pattern, in which case values carry the
+// radix marker of f. Intervals with a step are written as a step range like 1-9/2, or
+// as both of their values when they only hold two, so they are written as quickly as
+// they are stored. Runs of at least autostep single values with the same distance
+// between them are also written as a step range, autostep 0 leaves them as they are.
+func (r rangeSet) format(f valueFormat, autostep int) ([]string, bool) {
+	// Only a single value is written without brackets.
+	bracket := len(r) > 1 || len(r) == 1 && r[0].lo != r[0].hi
+	value := func(v uint64) string {
+		if bracket {
+			return f.marker() + f.format(v)
+		}
+		return f.format(v)
+	}
+
+	type element struct {
+		lo   uint64
+		text string
+	}
+	var elements []element
+	var plain []interval
+	for _, iv := range r {
+		if iv.step == 0 {
+			plain = append(plain, iv)
+			continue
+		}
+		if iv.hi-iv.lo == iv.step {
+			plain = append(plain, interval{lo: iv.lo, hi: iv.lo}, interval{lo: iv.hi, hi: iv.hi})
+			continue
+		}
+		elements = append(elements, element{lo: iv.lo, text: fmt.Sprintf("%s-%s/%d", value(iv.lo), value(iv.hi), iv.step)})
+	}
+
+	runs := rangeSetOf(plain)
+	for i := 0; i < len(runs); i++ {
+		iv := runs[i]
+		if autostep > 0 {
+			if n := runs.progression(i); n >= max(autostep, 2) {
+				last := runs[i+n-1].lo
+				elements = append(elements, element{lo: iv.lo, text: fmt.Sprintf("%s-%s/%d", value(iv.lo), value(last), runs[i+1].lo-iv.lo)})
+				i += n - 1
+				continue
+			}
+		}
+		if iv.lo == iv.hi {
+			elements = append(elements, element{lo: iv.lo, text: value(iv.lo)})
+		} else {
+			elements = append(elements, element{lo: iv.lo, text: value(iv.lo) + "-" + value(iv.hi)})
+		}
+	}
+
+	slices.SortFunc(elements, func(a, b element) int {
+		return cmp.Compare(a.lo, b.lo)
+	})
+	ranges := make([]string, len(elements))
+	for i, e := range elements {
+		ranges[i] = e.text
+	}
+	return ranges, bracket
+}
+
+// progression returns the number of single value intervals of r starting at i that form
+// an arithmetic progression, 0 if the interval at i holds more than one value.
+func (r rangeSet) progression(i int) int {
+	if r[i].lo != r[i].hi {
+		return 0
+	}
+	n := 1
+	for j := i + 1; j < len(r) && r[j].lo == r[j].hi; j++ {
+		if j > i+1 && r[j].lo-r[j-1].lo != r[i+1].lo-r[i].lo {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// intersect returns a new rangeSet containing the values present in both r and o.
+func (r rangeSet) intersect(o rangeSet) rangeSet {
+	if r.stepped() || o.stepped() {
+		var result []interval
+		for _, a := range r {
+			for _, b := range o {
+				if b.lo > a.hi {
+					break
+				}
+				if c, ok := a.intersect(b); ok {
+					result = append(result, c)
+				}
+			}
+		}
+		return rangeSetOf(result)
+	}
+
+	var result rangeSet
+	i, j := 0, 0
+	for i < len(r) && j < len(o) {
+		lo := max(r[i].lo, o[j].lo)
+		hi := min(r[i].hi, o[j].hi)
+		if lo <= hi {
+			result = append(result, interval{lo: lo, hi: hi})
+		}
+		if r[i].hi < o[j].hi {
+			i++
+		} else {
+			j++
+		}
+	}
+	return result
+}
+
+// subtract returns a new rangeSet containing the values of r not present in o.
+func (r rangeSet) subtract(o rangeSet) rangeSet {
+	if r.stepped() || o.stepped() {
+		var result []interval
+		for _, iv := range r {
+			result = append(result, subtractAll(iv, o)...)
+		}
+		return rangeSetOf(result)
+	}
+
+	var result rangeSet
+	j := 0
+	for _, iv := range r {
+		// Skip intervals of o entirely below the current interval.
+		for j < len(o) && o[j].hi < iv.lo {
+			j++
+		}
+
+		lo, covered := iv.lo, false
+		for k := j; k < len(o) && o[k].lo <= iv.hi; k++ {
+			if o[k].lo > lo {
+				result = append(result, interval{lo: lo, hi: o[k].lo - 1})
+			}
+			if o[k].hi >= iv.hi {
+				covered = true
+				break
+			}
+			lo = o[k].hi + 1
+		}
+		if !covered {
+			result = append(result, interval{lo: lo, hi: iv.hi})
+		}
+	}
+	return result
+}
+
+// subtractAll returns intervals holding the values of iv not present in any of the
+// intervals, which are sorted by their first value. Pieces of iv ending before the
+// first value of an interval are done, as the intervals after it start later still.
+func subtractAll(iv interval, intervals []interval) []interval {
+	var done []interval
+	pieces := []interval{iv}
+	for _, o := range intervals {
+		if o.lo > iv.hi || len(pieces) == 0 {
+			break
+		}
+		if o.hi < iv.lo {
+			continue
+		}
+		var remaining []interval
+		for _, piece := range pieces {
+			if piece.hi < o.lo {
+				done = append(done, piece)
+				continue
+			}
+			remaining = append(remaining, piece.subtract(o)...)
+		}
+		pieces = remaining
+	}
+	return append(done, pieces...)
+}
+
+// newRangeSet returns a rangeSet containing the given values, which may be unsorted and contain duplicates.
+func newRangeSet(values []uint64) rangeSet {
+	intervals := make([]interval, len(values))
+	for i, v := range values {
+		intervals[i] = interval{lo: v, hi: v}
+	}
+	return rangeSetOf(intervals)
+}
+
+// rangeSetOf returns a rangeSet containing the values of the given intervals, which may
+// be unsorted and overlap. Intervals with a step are merged with those of the same step
+// continuing them, and then only keep the values not held by the intervals before them.
+func rangeSetOf(intervals []interval) rangeSet {
+	var contiguous, stepped []interval
+	for _, iv := range intervals {
+		if iv.step == 0 {
+			contiguous = append(contiguous, iv)
+		} else {
+			stepped = append(stepped, iv)
+		}
+	}
+	result := mergeIntervals(contiguous)
+	if len(stepped) == 0 {
+		return result
+	}
+
+	slices.SortFunc(stepped, func(a, b interval) int {
+		return cmp.Or(cmp.Compare(a.step, b.step), cmp.Compare(a.lo%a.step, b.lo%b.step), cmp.Compare(a.lo, b.lo))
+	})
+	var progressions []interval
+	for _, iv := range stepped {
+		if n := len(progressions); n > 0 {
+			last := &progressions[n-1]
+			if last.step == iv.step && last.lo%last.step == iv.lo%iv.step && (iv.lo <= last.hi || iv.lo-last.hi == iv.step) {
+				last.hi = max(last.hi, iv.hi)
+				continue
+			}
+		}
+		progressions = append(progressions, iv)
+	}
+
+	values := []interval(result)
+	byLo := func(a, b interval) int {
+		return cmp.Compare(a.lo, b.lo)
+	}
+	for _, iv := range progressions {
+		pieces := subtractAll(iv, values)
+		slices.SortFunc(pieces, byLo)
+		values = mergeSorted(values, pieces, byLo)
+	}
+
+	// Single values left of a progression may continue the intervals without a step.
+	contiguous, stepped = nil, nil
+	for _, iv := range values {
+		if iv.step == 0 {
+			contiguous = append(contiguous, iv)
+		} else {
+			stepped = append(stepped, iv)
+		}
+	}
+	result = append(mergeIntervals(contiguous), stepped...)
+	slices.SortFunc(result, func(a, b interval) int {
+		return cmp.Compare(a.lo, b.lo)
+	})
+	return result
+}
+
+// mergeSorted returns the elements of a and b, which are both sorted by cmp, as a
+// single sorted slice.
+func mergeSorted(a, b []interval, cmp func(x, y interval) int) []interval {
+	result := make([]interval, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if cmp(b[0], a[0]) < 0 {
+			result = append(result, b[0])
+			b = b[1:]
+		} else {
+			result = append(result, a[0])
+			a = a[1:]
+		}
+	}
+	return append(append(result, a...), b...)
+}
+
+// mergeIntervals returns a rangeSet containing the values of intervals without a step,
+// which may be unsorted and overlap.
+func mergeIntervals(intervals []interval) rangeSet {
+	sorted := slices.Clone(intervals)
+	slices.SortFunc(sorted, func(a, b interval) int {
+		return cmp.Compare(a.lo, b.lo)
+	})
+
+	var result rangeSet
+	for _, iv := range sorted {
+		if n := len(result); n > 0 && (result[n-1].hi == math.MaxUint64 || iv.lo <= result[n-1].hi+1) {
+			result[n-1].hi = max(result[n-1].hi, iv.hi)
+			continue
+		}
+		result = append(result, iv)
+	}
+	return result
+}
+
+// len returns the number of values in r, false if the number overflows an uint64.
+func (r rangeSet) len() (uint64, bool) {
+	var total uint64
+	for _, iv := range r {
+		n, ok := iv.count()
+		if !ok {
+			return 0, false
+		}
+		var carry uint64
+		total, carry = bits.Add64(total, n, 0)
+		if carry != 0 {
+			return 0, false
+		}
+	}
+	return total, true
+}
+
+// last returns the largest value of r, which must not be empty.
+func (r rangeSet) last() uint64 {
+	var v uint64
+	for _, iv := range r {
+		v = max(v, iv.hi)
+	}
+	return v
+}
+
+// values returns an iterator over the values of r in ascending order. Intervals with a
+// step are walked alongside the others, each step taking the lowest pending value.
+func (r rangeSet) values() iter.Seq[uint64] {
+	return func(yield func(uint64) bool) {
+		if !r.stepped() {
+			for _, iv := range r {
+				for v := range iv.values() {
+					if !yield(v) {
+						return
+					}
+				}
+			}
+			return
+		}
+
+		// Each cursor walks intervals whose spans don't overlap, a cursor per interval with
+		// a step and one for all intervals without a step.
+		type cursor struct {
+			intervals []interval
+			value     uint64
+		}
+		var cursors []*cursor
+		var plain []interval
+		for _, iv := range r {
+			if iv.step == 0 {
+				plain = append(plain, iv)
+			} else {
+				cursors = append(cursors, &cursor{intervals: []interval{iv}, value: iv.lo})
+			}
+		}
+		if plain != nil {
+			cursors = append(cursors, &cursor{intervals: plain, value: plain[0].lo})
+		}
+		for len(cursors) > 0 {
+			c := slices.MinFunc(cursors, func(x, y *cursor) int {
+				return cmp.Compare(x.value, y.value)
+			})
+			if !yield(c.value) {
+				return
+			}
+			switch iv := c.intervals[0]; {
+			case c.value != iv.hi:
+				c.value += iv.stride()
+			case len(c.intervals) > 1:
+				c.intervals = c.intervals[1:]
+				c.value = c.intervals[0].lo
+			default:
+				cursors = slices.DeleteFunc(cursors, func(x *cursor) bool {
+					return x == c
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package nodeset
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func Test_rangeSet(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      rangeSet
+		union     rangeSet
+		intersect rangeSet
+		subtract  rangeSet
+	}{
+		{
+			name:      "Overlapping",
+			a:         rangeSet{{1, 5, 0}},
+			b:         rangeSet{{3, 8, 0}},
+			union:     rangeSet{{1, 8, 0}},
+			intersect: rangeSet{{3, 5, 0}},
+			subtract:  rangeSet{{1, 2, 0}},
+		},
+		{
+			name:      "Adjacent",
+			a:         rangeSet{{1, 2, 0}},
+			b:         rangeSet{{3, 4, 0}},
+			union:     rangeSet{{1, 4, 0}},
+			intersect: nil,
+			subtract:  rangeSet{{1, 2, 0}},
+		},
+		{
+			name:      "Hole punched",
+			a:         rangeSet{{1, 10, 0}},
+			b:         rangeSet{{3, 4, 0}, {6, 6, 0}},
+			union:     rangeSet{{1, 10, 0}},
+			intersect: rangeSet{{3, 4, 0}, {6, 6, 0}},
+			subtract:  rangeSet{{1, 2, 0}, {5, 5, 0}, {7, 10, 0}},
+		},
+		{
+			name:      "Fully covered",
+			a:         rangeSet{{3, 4, 0}, {8, 9, 0}},
+			b:         rangeSet{{1, 10, 0}},
+			union:     rangeSet{{1, 10, 0}},
+			intersect: rangeSet{{3, 4, 0}, {8, 9, 0}},
+			subtract:  nil,
+		},
+		{
+			name:      "Maximum value",
+			a:         rangeSet{{0, math.MaxUint64, 0}},
+			b:         rangeSet{{math.MaxUint64 - 1, math.MaxUint64, 0}},
+			union:     rangeSet{{0, math.MaxUint64, 0}},
+			intersect: rangeSet{{math.MaxUint64 - 1, math.MaxUint64, 0}},
+			subtract:  rangeSet{{0, math.MaxUint64 - 2, 0}},
+		},
+		{
+			name:      "Step through interval",
+			a:         rangeSet{{1, 9, 2}},
+			b:         rangeSet{{4, 6, 0}},
+			union:     rangeSet{{1, 3, 2}, {4, 6, 0}, {7, 9, 2}},
+			intersect: rangeSet{{5, 5, 0}},
+			subtract:  rangeSet{{1, 3, 2}, {7, 9, 2}},
+		},
+		{
+			name:      "Interval through step",
+			a:         rangeSet{{1, 10, 0}},
+			b:         rangeSet{{2, 8, 3}},
+			union:     rangeSet{{1, 10, 0}},
+			intersect: rangeSet{{2, 8, 3}},
+			subtract:  rangeSet{{1, 1, 0}, {3, 4, 0}, {6, 7, 0}, {9, 10, 0}},
+		},
+		{
+			name:      "Overlapping steps",
+			a:         rangeSet{{0, 12, 2}},
+			b:         rangeSet{{0, 12, 3}},
+			union:     rangeSet{{0, 12, 2}, {3, 9, 6}},
+			intersect: rangeSet{{0, 12, 6}},
+			subtract:  rangeSet{{2, 4, 2}, {8, 10, 2}},
+		},
+		{
+			name:      "Continued step",
+			a:         rangeSet{{1, 9, 2}},
+			b:         rangeSet{{11, 21, 2}},
+			union:     rangeSet{{1, 21, 2}},
+			intersect: nil,
+			subtract:  rangeSet{{1, 9, 2}},
+		},
+		{
+			name:      "Steps without common values",
+			a:         rangeSet{{0, 20, 4}},
+			b:         rangeSet{{2, 22, 4}},
+			union:     rangeSet{{0, 20, 4}, {2, 22, 4}},
+			intersect: nil,
+			subtract:  rangeSet{{0, 20, 4}},
+		},
+		{
+			name:      "Maximum value steps",
+			a:         rangeSet{{0, math.MaxUint64 - 1, 2}},
+			b:         rangeSet{{0, math.MaxUint64, 3}},
+			union:     rangeSet{{0, math.MaxUint64 - 1, 2}, {3, math.MaxUint64, 6}},
+			intersect: rangeSet{{0, math.MaxUint64 - 3, 6}},
+			subtract:  rangeSet{{2, math.MaxUint64 - 1, 6}, {4, math.MaxUint64 - 5, 6}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.union(tt.b); !reflect.DeepEqual(got, tt.union) {
+				t.Errorf("union() = %v, want %v", got, tt.union)
+			}
+			if got := tt.a.intersect(tt.b); !reflect.DeepEqual(got, tt.intersect) {
+				t.Errorf("intersect() = %v, want %v", got, tt.intersect)
+			}
+			if got := tt.a.subtract(tt.b); !reflect.DeepEqual(got, tt.subtract) {
+				t.Errorf("subtract() = %v, want %v", got, tt.subtract)
+			}
+		})
+	}
+}
+
+func Test_newRangeSet(t *testing.T) {
+	got := newRangeSet([]uint64{5, 1, 2, 2, 3, 9, math.MaxUint64})
+	want := rangeSet{{1, 3, 0}, {5, 5, 0}, {9, 9, 0}, {math.MaxUint64, math.MaxUint64, 0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("newRangeSet() = %v, want %v", got, want)
+	}
+}
+
+func Test_rangeSet_values(t *testing.T) {
+	r := rangeSetOf([]interval{{1, 9, 2}, {4, 6, 0}, {12, 12, 0}, {10, 20, 5}})
+	var got []uint64
+	for v := range r.values() {
+		got = append(got, v)
+	}
+	want := []uint64{1, 3, 4, 5, 6, 7, 9, 10, 12, 15, 20}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("values() = %v, want %v", got, want)
+	}
+	if n, ok := r.len(); !ok || n != uint64(len(want)) {
+		t.Errorf("len() = %d, %v, want %d", n, ok, len(want))
+	}
+}
@@ -0,0 +1,133 @@
+package nodeset
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Regroup returns the nodes of ns as a pattern of group references of source, and of
+// the nodes not covered by any of its groups, like '@rack[1-3],node[401-402]'. Groups
+// are only used when every one of their nodes is part of ns, larger groups are preferred
+// over smaller ones, groups covered by the other chosen groups are left out, and the
+// names of the chosen groups are folded together. Groups are picked greedily, so the
+// result isn't always the shortest pattern of groups. Without any such group, or when
+// the folded form of ns is at most as long as the result, the folded form of ns is
+// returned instead. An empty source stands for the default source of r.
+func (ns *NodeSet) Regroup(r GroupResolver, source string) (string, error) {
+	names, err := r.Groups(source)
+	if err != nil {
+		return "", err
+	}
+	prefix := "@"
+	if source != "" {
+		prefix = "@" + source + ":"
+	}
+
+	type candidate struct {
+		name  string
+		nodes *NodeSet
+	}
+	var candidates []candidate
+	opts := Options{Resolver: r}
+	for _, name := range names {
+		nodes, err := ParseWithOptions(prefix+name, opts)
+		if err != nil {
+			return "", err
+		}
+		if !nodes.IsEmpty() && nodes.IsSubset(ns) {
+			candidates = append(candidates, candidate{name: name, nodes: nodes})
+		}
+	}
+	slices.SortStableFunc(candidates, func(x, y candidate) int {
+		return -cmp.Compare(x.nodes.Len(), y.nodes.Len())
+	})
+
+	// Greedily pick the largest groups still covering nodes left over.
+	var picked []candidate
+	remaining := ns
+	for _, c := range candidates {
+		if !c.nodes.Intersection(remaining).IsEmpty() {
+			picked = append(picked, c)
+			remaining = remaining.Difference(c.nodes)
+		}
+	}
+	// Drop groups made redundant by groups picked after them.
+	for i := 0; i < len(picked); {
+		others := &NodeSet{}
+		for j, c := range picked {
+			if j != i {
+				others = others.Union(c.nodes)
+			}
+		}
+		if picked[i].nodes.IsSubset(others) {
+			picked = slices.Delete(picked, i, i+1)
+			continue
+		}
+		i++
+	}
+
+	var groups []string
+	for _, c := range picked {
+		groups = append(groups, c.name)
+	}
+	var output []string
+	for _, pattern := range Fold(groups) {
+		output = append(output, prefix+pattern)
+	}
+	output = append(output, remaining.Fold()...)
+
+	regrouped, folded := strings.Join(output, ","), ns.String()
+	if len(folded) <= len(regrouped) {
+		return folded, nil
+	}
+	return regrouped, nil
+}
+
+// Regroup parses pattern with opts, see ParseWithOptions, and returns its nodes
+// expressed with the groups of source of opts.Resolver, see NodeSet.Regroup.
+func Regroup(pattern string, opts Options, source string) (string, error) {
+	if opts.Resolver == nil {
+		return "", fmt.Errorf("regroup %s, no group resolver configured: %w", pattern, ErrUnknownGroup)
+	}
+	ns, err := ParseWithOptions(pattern, opts)
+	if err != nil {
+		return "", err
+	}
+	return ns.Regroup(opts.Resolver, source)
+}
+
+// GroupMatch is a group holding some of the nodes given to GroupsOf.
+type GroupMatch struct {
+	Source string   // Source of the group.
+	Group  string   // Name of the group.
+	Nodes  *NodeSet // Nodes given to GroupsOf that are part of the group.
+	Full   bool     // Whether every node given to GroupsOf is part of the group.
+}
+
+// GroupsOf returns the groups of every source of r holding at least one node of ns,
+// sorted by source and group name. Full is set for groups holding every node of ns,
+// while Nodes tells which nodes of ns a group partially holds.
+func GroupsOf(ns *NodeSet, r GroupResolver) ([]GroupMatch, error) {
+	var matches []GroupMatch
+	opts := Options{Resolver: r}
+	for _, source := range r.Sources() {
+		names, err := r.Groups(source)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			nodes, err := ParseWithOptions("@"+source+":"+name, opts)
+			if err != nil {
+				return nil, err
+			}
+			common := ns.Intersection(nodes)
+			if common.IsEmpty() {
+				continue
+			}
+			matches = append(matches, GroupMatch{Source: source, Group: name, Nodes: common, Full: ns.IsSubset(nodes)})
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,142 @@
+package nodeset
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRegroup(t *testing.T) {
+	r := &MapResolver{
+		Default: "local",
+		Map: map[string]map[string]string{
+			"local": {
+				"rack1":   "node[1-100]",
+				"rack2":   "node[101-200]",
+				"rack3":   "node[201-300]",
+				"rack4":   "node[301-400]",
+				"gpu":     "node[181-220]",
+				"login":   "login[1-2]",
+				"compute": "node[1-400]",
+				"empty":   "",
+			},
+			"slurm": {
+				"debug": "node[1-2]",
+				"batch": "node[3-400]",
+			},
+			"site": {
+				"all": "node[1-400],login[1-2]",
+				"n1":  "node1",
+				"n2":  "node2",
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		source  string
+		want    string
+	}{
+		{name: "Groups and leftover nodes", pattern: "node[1-100,102,104,201-300]", want: "@rack[1,3],node[102,104]"},
+		{name: "Folded form shorter than groups and leftover nodes", pattern: "node[1-300,401-402]", want: "node[1-300,401-402]"},
+		{name: "Largest group preferred", pattern: "node[1-400],login[1-2]", want: "@login,@compute"},
+		{name: "Overlapping groups", pattern: "node[101-220]", want: "@rack2,@gpu"},
+		{name: "Redundant group dropped", pattern: "node[181-300]", want: "@rack3,@gpu"},
+		{name: "No group fits", pattern: "node[1-50]", want: "node[1-50]"},
+		{name: "Group and a node", pattern: "login[1-2],node1", want: "@login,node1"},
+		{name: "Other source", pattern: "node[1-400],login[1-2]", source: "site", want: "@site:all"},
+		{name: "Folded form shorter than groups", pattern: "node[1-400]", source: "slurm", want: "node[1-400]"},
+		{name: "Folded form shorter than single node groups", pattern: "node[1-2]", source: "site", want: "node[1-2]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Regroup(tt.pattern, Options{Resolver: r}, tt.source)
+			if err != nil {
+				t.Fatalf("Regroup() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Regroup() = %v, want %v", got, tt.want)
+			}
+
+			// The regrouped pattern resolves back to the same nodes.
+			ns, err := Parse(tt.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			back, err := ParseWithOptions(got, Options{Resolver: r})
+			if err != nil {
+				t.Fatalf("ParseWithOptions() error = %v", err)
+			}
+			if !back.Equal(ns) {
+				t.Errorf("ParseWithOptions(Regroup()) = %v, want %v", back, ns)
+			}
+		})
+	}
+}
+
+func TestRegroupErrors(t *testing.T) {
+	if _, err := Regroup("node1", Options{}, ""); !errors.Is(err, ErrUnknownGroup) {
+		t.Errorf("Regroup() without resolver error = %v, want ErrUnknownGroup", err)
+	}
+	if _, err := Regroup("node1", Options{Resolver: testResolver()}, "missing"); !errors.Is(err, ErrUnknownSource) {
+		t.Errorf("Regroup() of unknown source error = %v, want ErrUnknownSource", err)
+	}
+	if _, err := Regroup("node1", Options{Resolver: testResolver()}, ""); !errors.Is(err, ErrGroupCycle) {
+		t.Errorf("Regroup() with a group cycle error = %v, want ErrGroupCycle", err)
+	}
+}
+
+func TestGroupsOf(t *testing.T) {
+	r := &MapResolver{
+		Default: "rack",
+		Map: map[string]map[string]string{
+			"rack": {
+				"r1": "node[1-4]",
+				"r2": "node[5-8]",
+			},
+			"role": {
+				"compute": "node[1-8]",
+				"login":   "login[1-2]",
+				"service": "node8,login1",
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    []string
+	}{
+		{name: "Single node", pattern: "node8", want: []string{"rack:r2 full node8", "role:compute full node8", "role:service full node8"}},
+		{name: "Full and partial", pattern: "node[3-5]", want: []string{"rack:r1 partial node[3-4]", "rack:r2 partial node5", "role:compute full node[3-5]"}},
+		{name: "No group", pattern: "other1", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns, err := Parse(tt.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			matches, err := GroupsOf(ns, r)
+			if err != nil {
+				t.Fatalf("GroupsOf() error = %v", err)
+			}
+			var got []string
+			for _, m := range matches {
+				containment := "partial"
+				if m.Full {
+					containment = "full"
+				}
+				got = append(got, m.Source+":"+m.Group+" "+containment+" "+m.Nodes.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GroupsOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := GroupsOf(NewNodeSet("node1"), testResolver()); !errors.Is(err, ErrGroupCycle) {
+		t.Errorf("GroupsOf() with a group cycle error = %v, want ErrGroupCycle", err)
+	}
+}
@@ -0,0 +1,259 @@
+package nodeset
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// DefaultSlurmConf returns the slurm.conf file read by the nodeset command, the file
+// named by the SLURM_CONF environment variable, or /etc/slurm/slurm.conf.
+func DefaultSlurmConf() string {
+	if path := os.Getenv("SLURM_CONF"); path != "" {
+		return path
+	}
+	return "/etc/slurm/slurm.conf"
+}
+
+// LoadSlurmConf reads the NodeName, NodeSet and PartitionName lines of a slurm.conf file,
+// and of the files it includes, and returns them as a MapResolver with two sources:
+// partition, holding a group per partition, and feature, holding a group per feature of
+// the Features of the NodeName lines. For example:
+//
+//	NodeName=DEFAULT CPUs=64
+//	NodeName=node[1-32] Features=rack1
+//	NodeName=gpu[1-8] Features=gpu,a100
+//	PartitionName=batch Nodes=node[1-32] Default=YES
+//	PartitionName=gpu Nodes=gpu[1-8]
+//
+// results in the groups @partition:batch, @partition:gpu, @feature:rack1, @feature:gpu
+// and @feature:a100. Hostlists are parsed with Parse, the Nodes of a partition may name
+// a NodeSet line or be ALL, and Include directives are resolved relative to the
+// directory of path. The default source is partition.
+func LoadSlurmConf(path string) (*MapResolver, error) {
+	c := &slurmConf{
+		nodes:      &NodeSet{},
+		features:   make(map[string]*NodeSet),
+		nodeSets:   make(map[string]slurmNodeSet),
+		partitions: make(map[string]string),
+		dir:        filepath.Dir(path),
+		included:   make(map[string]bool),
+	}
+	if err := c.load(path); err != nil {
+		return nil, err
+	}
+	return c.resolver()
+}
+
+// slurmConf collects the lines of a slurm.conf file relevant to groups.
+type slurmConf struct {
+	nodes           *NodeSet                // Nodes of every NodeName line.
+	features        map[string]*NodeSet     // Nodes per feature.
+	defaultFeatures []string                // Features of the NodeName=DEFAULT line.
+	nodeSets        map[string]slurmNodeSet // NodeSet lines per name.
+	partitions      map[string]string       // Nodes parameter per partition name.
+	dir             string                  // Directory relative includes are resolved against.
+	included        map[string]bool         // Files already read, to skip include cycles.
+}
+
+// slurmNodeSet is a NodeSet line, naming the nodes of a hostlist and of a feature.
+type slurmNodeSet struct {
+	nodes, feature string
+}
+
+// load reads the slurm.conf file at path.
+func (c *slurmConf) load(path string) error {
+	if c.included[path] {
+		return nil
+	}
+	c.included[path] = true
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if err := c.parseLine(scanner.Text()); err != nil {
+			return fmt.Errorf("slurm config %s, line %d, %w", path, line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// parseLine handles a single line of a slurm.conf file.
+func (c *slurmConf) parseLine(line string) error {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		line = line[:i]
+	}
+	fields := splitSlurmFields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	if strings.EqualFold(fields[0], "include") {
+		if len(fields) != 2 {
+			return fmt.Errorf("include expects a single file")
+		}
+		return c.include(fields[1])
+	}
+
+	key, value, _ := strings.Cut(fields[0], "=")
+	params := slurmParams(fields[1:])
+	switch strings.ToLower(key) {
+	case "nodename":
+		return c.addNodes(value, params)
+	case "nodeset":
+		c.nodeSets[value] = slurmNodeSet{nodes: params["nodes"], feature: params["feature"]}
+	case "partitionname":
+		if !strings.EqualFold(value, "default") {
+			c.partitions[value] = params["nodes"]
+		}
+	}
+	return nil
+}
+
+// include reads the files matching an Include directive.
+func (c *slurmConf) include(pattern string) error {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(c.dir, pattern)
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("include %s, %w", pattern, fs.ErrNotExist)
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		if err := c.load(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addNodes adds the nodes of a NodeName line, and the nodes of each of its features.
+func (c *slurmConf) addNodes(hostlist string, params map[string]string) error {
+	features := c.defaultFeatures
+	if value, ok := params["features"]; ok {
+		features = strings.Split(value, ",")
+	} else if value, ok := params["feature"]; ok {
+		features = strings.Split(value, ",")
+	}
+	if strings.EqualFold(hostlist, "default") {
+		c.defaultFeatures = features
+		return nil
+	}
+
+	ns, err := Parse(hostlist)
+	if err != nil {
+		return err
+	}
+	c.nodes = c.nodes.Union(ns)
+	for _, feature := range features {
+		if feature != "" {
+			c.features[feature] = c.features[feature].Union(ns)
+		}
+	}
+	return nil
+}
+
+// resolver returns the partitions and features of c as a MapResolver.
+func (c *slurmConf) resolver() (*MapResolver, error) {
+	m := &MapResolver{
+		Default: "partition",
+		Map: map[string]map[string]string{
+			"partition": make(map[string]string),
+			"feature":   make(map[string]string),
+		},
+	}
+	for feature, ns := range c.features {
+		m.Map["feature"][feature] = ns.String()
+	}
+	for name, nodes := range c.partitions {
+		ns, err := c.partitionNodes(nodes)
+		if err != nil {
+			return nil, fmt.Errorf("partition %s, %w", name, err)
+		}
+		m.Map["partition"][name] = ns.String()
+	}
+	return m, nil
+}
+
+// partitionNodes returns the nodes of the Nodes parameter of a partition, a comma
+// separated list of hostlists, NodeSet names or ALL.
+func (c *slurmConf) partitionNodes(nodes string) (*NodeSet, error) {
+	result := &NodeSet{}
+	if nodes == "" {
+		return result, nil
+	}
+	for _, element := range SplitOnComma(nodes) {
+		nodeSet, ok := c.nodeSets[element]
+		if !ok {
+			nodeSet = slurmNodeSet{nodes: element}
+		}
+		if nodeSet.nodes != "" {
+			ns, err := c.hostlist(nodeSet.nodes)
+			if err != nil {
+				return nil, err
+			}
+			result = result.Union(ns)
+		}
+		if nodeSet.feature != "" {
+			result = result.Union(c.features[nodeSet.feature])
+		}
+	}
+	return result, nil
+}
+
+// hostlist returns the nodes of a hostlist, or every node for ALL.
+func (c *slurmConf) hostlist(hostlist string) (*NodeSet, error) {
+	if strings.EqualFold(hostlist, "all") {
+		return c.nodes, nil
+	}
+	return Parse(hostlist)
+}
+
+// splitSlurmFields splits a slurm.conf line on whitespace, except for within double quotes.
+func splitSlurmFields(line string) []string {
+	var fields []string
+	var field strings.Builder
+	quoted, inField := false, false
+	for _, char := range line {
+		switch {
+		case char == '"':
+			quoted = !quoted
+			inField = true
+		case !quoted && (char == ' ' || char == '\t'):
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(char)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// slurmParams returns the Key=Value fields of a slurm.conf line, keyed by lower case key.
+func slurmParams(fields []string) map[string]string {
+	params := make(map[string]string, len(fields))
+	for _, field := range fields {
+		if key, value, ok := strings.Cut(field, "="); ok {
+			params[strings.ToLower(key)] = value
+		}
+	}
+	return params
+}
@@ -0,0 +1,93 @@
+package nodeset
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadSlurmConf(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	writeFile("nodes.conf", `
+NodeName=DEFAULT CPUs=64 Features=cpu
+NodeName=node[1-8] RealMemory=256000
+NodeName=gpu[1-4] Features="gpu,a100" Gres=gpu:4
+NodeName=tux[0-3]-ib Feature=ib
+`)
+	path := writeFile("slurm.conf", `
+ClusterName=test
+# Nodes are defined in another file
+Include nodes.conf
+include slurm.conf
+NodeSet=ibnodes Feature=ib
+PartitionName=DEFAULT MaxTime=INFINITE
+PartitionName=batch Nodes=node[1-8] Default=YES
+PartitionName=gpu Nodes=gpu[1-4],node8
+PartitionName=ib Nodes=ibnodes
+partitionname=all nodes=ALL
+`)
+
+	r, err := LoadSlurmConf(path)
+	if err != nil {
+		t.Fatalf("LoadSlurmConf() error = %v", err)
+	}
+	if got, want := r.Sources(), []string{"feature", "partition"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Sources() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: "@batch", want: "node[1-8]"},
+		{pattern: "@partition:gpu", want: "node8,gpu[1-4]"},
+		{pattern: "@partition:ib", want: "tux[0-3]-ib"},
+		{pattern: "@partition:all", want: "tux[0-3]-ib,node[1-8],gpu[1-4]"},
+		{pattern: "@feature:cpu", want: "node[1-8]"},
+		{pattern: "@feature:a100", want: "gpu[1-4]"},
+		{pattern: "@feature:*", want: "tux[0-3]-ib,node[1-8],gpu[1-4]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, err := ParseWithOptions(tt.pattern, Options{Resolver: r})
+			if err != nil {
+				t.Fatalf("ParseWithOptions() error = %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("ParseWithOptions() = %v, want %v", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadSlurmConfErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "Missing include", data: "Include missing.conf\n"},
+		{name: "Invalid hostlist", data: "NodeName=node[1-2 CPUs=4\n"},
+		{name: "Invalid partition nodes", data: "PartitionName=batch Nodes=node[2-1]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "slurm.conf")
+			if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadSlurmConf(path); err == nil {
+				t.Errorf("LoadSlurmConf() error = nil, want error")
+			}
+		})
+	}
+}